@@ -0,0 +1,187 @@
+// Package auth provides a middleware for the toolkit server that authenticates requests via JSON Web Tokens
+// sent as bearer token in the Authorization header.
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// SubjectLogField is the name of the log field that contains the subject of the token.
+const SubjectLogField = "subject"
+
+var (
+	hmacAlgorithms       = []string{"HS256", "HS384", "HS512"}
+	asymmetricAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// Config configures the authentication middleware. Either HMACSecret or Keys needs to be set.
+type Config struct {
+	// HMACSecret is used to verify tokens signed with HS256, HS384 or HS512.
+	HMACSecret []byte
+	// Keys provides the public keys to verify tokens signed with RSA (RS*, PS*) or ECDSA (ES*) by key ID,
+	// e.g. NewRemoteKeySet for the JWKS URL of the identity provider or NewKeySetFromFile.
+	Keys KeyProvider
+	// Issuer is compared with the "iss" claim if set.
+	Issuer string
+	// Audience needs to be contained in the "aud" claim if set.
+	Audience string
+	// Leeway is tolerated when checking the times in the token to compensate for clock skew.
+	Leeway time.Duration
+	// AllowMissingExpiry accepts tokens without the "exp" claim. By default they are rejected.
+	AllowMissingExpiry bool
+	// Skipper defines requests that do not need to be authenticated, e.g. health checks (optional).
+	Skipper func(c echo.Context) bool
+}
+
+// Middleware returns a middleware that only lets requests with a valid bearer token pass. Other requests receive
+// a 401 response with the WWW-Authenticate header. The claims are stored in the echo context (see ClaimsFromContext
+// and Subject) and the subject is added to the log fields of the request specific Obs (see Obs.CopyWithRequest).
+// It panics if neither HMACSecret nor Keys are set.
+func Middleware(config Config) echo.MiddlewareFunc {
+	parser := newParser(config)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper != nil && config.Skipper(c) {
+				return next(c)
+			}
+
+			claims, err := parser.parse(c.Request().Header.Get(echo.HeaderAuthorization), time.Now())
+			if err != nil {
+				return unauthorized(c, err)
+			}
+
+			c.Set(ClaimsKey, claims)
+			c.Set(SubjectKey, claims.Subject)
+			req := c.Request()
+			ctx := observance.ContextWithLogFields(req.Context(), observance.Fields{SubjectLogField: claims.Subject})
+			c.SetRequest(req.WithContext(ctx))
+
+			return next(c)
+		}
+	}
+}
+
+// unauthorized sets the WWW-Authenticate header and returns the 401 error. Errors of the KeyProvider, e.g. if the
+// JWKS could not be fetched, are logged and not sent to the client.
+func unauthorized(c echo.Context, err error) error {
+	var keyErr *keyProviderError
+	if errors.As(err, &keyErr) {
+		c.Logger().Error(fmt.Errorf("failed to get the key for the token: %w", keyErr.err))
+		err = errTokenNotVerified
+	}
+
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, err.Error()))
+	return httperrors.New(http.StatusUnauthorized, err.Error())
+}
+
+// errTokenNotVerified is sent to the client instead of the errors of the KeyProvider.
+var errTokenNotVerified = errors.New("token could not be verified")
+
+// keyProviderError marks errors of the KeyProvider other than ErrUnknownKey.
+type keyProviderError struct {
+	err error
+}
+
+func (e *keyProviderError) Error() string {
+	return e.err.Error()
+}
+
+func (e *keyProviderError) Unwrap() error {
+	return e.err
+}
+
+type parser struct {
+	config Config
+	jwt    *jwt.Parser
+}
+
+func newParser(config Config) *parser {
+	algorithms := []string{}
+	if len(config.HMACSecret) > 0 {
+		algorithms = append(algorithms, hmacAlgorithms...)
+	}
+	if config.Keys != nil {
+		algorithms = append(algorithms, asymmetricAlgorithms...)
+	}
+	if len(algorithms) == 0 {
+		panic("auth: either HMACSecret or Keys needs to be configured")
+	}
+
+	return &parser{
+		config: config,
+		// The claims are validated by the parser itself to support the leeway and lists as audience.
+		jwt: &jwt.Parser{ValidMethods: algorithms, SkipClaimsValidation: true},
+	}
+}
+
+func (p *parser) parse(header string, now time.Time) (*Claims, error) {
+	// The authentication scheme is case-insensitive, see RFC 7235.
+	scheme, tokenString, found := strings.Cut(header, " ")
+	tokenString = strings.TrimSpace(tokenString)
+	if !found || !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+		return nil, errors.New("bearer token missing")
+	}
+
+	token, err := p.jwt.Parse(tokenString, p.key)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Inner != nil {
+			err = validationErr.Inner
+		}
+		return nil, fmt.Errorf("token is invalid: %w", err)
+	}
+
+	claims, err := parseClaims(token.Claims.(jwt.MapClaims))
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.validate(p.config, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the key for the algorithm of the token. The type of the key is checked to prevent that
+// a token is e.g. signed with HMAC using the public RSA key as secret.
+func (p *parser) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return p.config.HMACSecret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := p.publicKey(token)
+		if _, ok := key.(*rsa.PublicKey); err == nil && !ok {
+			return nil, errors.New("key is no RSA key")
+		}
+		return key, err
+	case *jwt.SigningMethodECDSA:
+		key, err := p.publicKey(token)
+		if _, ok := key.(*ecdsa.PublicKey); err == nil && !ok {
+			return nil, errors.New("key is no ECDSA key")
+		}
+		return key, err
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %s", token.Method.Alg())
+	}
+}
+
+func (p *parser) publicKey(token *jwt.Token) (interface{}, error) {
+	keyID, _ := token.Header["kid"].(string)
+	key, err := p.config.Keys.Key(keyID)
+	if err != nil && !errors.Is(err, ErrUnknownKey) {
+		return nil, &keyProviderError{err: err}
+	}
+	return key, err
+}
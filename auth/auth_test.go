@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+func TestMiddlewareJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks := testJWKS(t, rsaKey, ecKey)
+
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+	rsaToken := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims)
+	psToken := signToken(t, jwt.SigningMethodPS256, "rsa-1", rsaKey, claims)
+	ecToken := signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, claims)
+	unknownKeyToken := signToken(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, claims)
+	wrongTypeToken := signToken(t, jwt.SigningMethodES256, "rsa-1", ecKey, claims)
+	// The public key must not be accepted as HMAC secret.
+	confusedToken := signToken(t, jwt.SigningMethodHS256, "rsa-1", jwks, claims)
+
+	assertTokens := func(t *testing.T, keys KeyProvider) {
+		e := newTestServer(t)
+		e.Use(Middleware(Config{Keys: keys}))
+		e.GET("/", func(c echo.Context) error { return c.String(http.StatusOK, Subject(c)) })
+
+		for token, status := range map[string]int{
+			rsaToken:        http.StatusOK,
+			psToken:         http.StatusOK,
+			ecToken:         http.StatusOK,
+			unknownKeyToken: http.StatusUnauthorized,
+			wrongTypeToken:  http.StatusUnauthorized,
+			confusedToken:   http.StatusUnauthorized,
+		} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, status, rec.Code, rec.Header().Get(echo.HeaderWWWAuthenticate))
+		}
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, os.WriteFile(path, jwks, 0600))
+		keys, err := NewKeySetFromFile(path)
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+		assertTokens(t, keys)
+
+		_, err = NewKeySetFromFile(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			_, _ = w.Write(jwks)
+		}))
+		defer server.Close()
+
+		keys := NewRemoteKeySet(server.URL, 0)
+		assertTokens(t, keys)
+		assert.Equal(t, 1, requests, "the unknown key ID triggers no refresh directly after the first fetch")
+	})
+}
+
+func TestRemoteKeySetCaching(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks := testJWKS(t, rsaKey, ecKey)
+
+	requests := 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	keys := NewRemoteKeySet(server.URL, 10*time.Minute)
+	keys.now = func() time.Time { return now }
+
+	key, err := keys.Key("rsa-1")
+	require.NoError(t, err)
+	assert.Equal(t, &rsaKey.PublicKey, key)
+	_, err = keys.Key("ec-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, requests, "the keys are cached")
+
+	_, err = keys.Key("rsa-2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 1, requests, "refreshes are limited")
+
+	now = now.Add(2 * time.Minute)
+	_, err = keys.Key("rsa-2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	assert.Equal(t, 2, requests, "unknown key IDs trigger a refresh")
+
+	now = now.Add(10 * time.Minute)
+	fail = true
+	_, err = keys.Key("rsa-1")
+	assert.NoError(t, err, "cached keys are used if the refresh fails")
+	assert.Equal(t, 3, requests)
+
+	_, err = NewRemoteKeySet(server.URL, 0).Key("rsa-1")
+	assert.EqualError(t, err, "failed to fetch JWKS: unexpected status 503")
+}
+
+func TestRemoteKeySetConcurrentFetch(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwks := testJWKS(t, rsaKey, ecKey)
+
+	var requests int32
+	fetching := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			fetching <- struct{}{}
+			<-release
+		}
+		_, _ = w.Write(jwks)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	keys := NewRemoteKeySet(server.URL, 10*time.Minute)
+	keys.now = func() time.Time { return now }
+	_, err = keys.Key("rsa-1")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key("rsa-2")
+			assert.ErrorIs(t, err, ErrUnknownKey)
+		}()
+	}
+	<-fetching
+
+	key, err := keys.Key("rsa-1")
+	require.NoError(t, err, "cached keys are returned while the document is fetched")
+	assert.Equal(t, &rsaKey.PublicKey, key)
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "concurrent calls share the fetch")
+}
+
+type failingKeyProvider struct{}
+
+func (failingKeyProvider) Key(keyID string) (interface{}, error) {
+	return nil, errors.New("failed to fetch JWKS: dial tcp 10.0.0.1:443: connection refused")
+}
+
+func TestMiddlewareKeyProviderError(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	token := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	testLogger := observance.NewTestLogger()
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: testLogger})
+	require.NoError(t, err)
+	e.Use(Middleware(Config{Keys: failingKeyProvider{}}))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.JSONEq(t, `{"message":"token could not be verified"}`, rec.Body.String())
+	assert.Equal(t, `Bearer error="invalid_token", error_description="token could not be verified"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+	assert.Contains(t, testLogger.LastEntry().Message, "connection refused", "the cause is logged")
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys, err := ParseJWKS([]byte(`{"keys":[{"kty":"oct","kid":"a","k":"c2VjcmV0"},{"kty":"RSA","kid":"b","use":"enc","n":"AQAB","e":"AQAB"}]}`))
+	require.NoError(t, err)
+	assert.Empty(t, keys, "symmetric and encryption keys are skipped")
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","kid":"a","crv":"P-256","x":"AQAB","y":"AQAB"}]}`))
+	assert.EqualError(t, err, `failed to parse key "a": point is not on the curve`)
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"a","e":"AQAB"}]}`))
+	assert.EqualError(t, err, `failed to parse key "a": missing key parameter`)
+
+	_, err = ParseJWKS([]byte(`[]`))
+	assert.Error(t, err)
+}
+
+func newTestServer(t *testing.T) *echo.Echo {
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	return e
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, keyID string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func testJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) []byte {
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	document := map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		},
+	}
+	data, err := json.Marshal(document)
+	require.NoError(t, err)
+	return data
+}
+
+func TestBearerScheme(t *testing.T) {
+	secret := []byte("test-secret")
+	p := newParser(Config{HMACSecret: secret})
+
+	for _, header := range []string{"Bearer", "Bearer ", "Token abc"} {
+		_, err := p.parse(header, time.Now())
+		assert.EqualError(t, err, "bearer token missing", header)
+	}
+
+	token := signToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	claims, err := p.parse("bearer "+token, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+}
@@ -0,0 +1,80 @@
+// Package authtest provides utilities to test handlers that are protected by the auth middleware.
+package authtest
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/auth"
+)
+
+// Defaults of the Issuer.
+const (
+	DefaultIssuer   = "https://issuer.test"
+	DefaultAudience = "test"
+)
+
+// Issuer mints HMAC signed tokens for handler tests, e.g. with handlertest.Suite:
+//
+//	issuer := authtest.NewIssuer()
+//	s := handlertest.Suite{
+//		DefaultMiddleware: []echo.MiddlewareFunc{auth.Middleware(issuer.Config())},
+//		DefaultHeaders:    issuer.Headers("user-1"),
+//	}
+type Issuer struct {
+	Secret   []byte
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+}
+
+// NewIssuer creates an issuer with a fixed secret, issuer and audience. Tokens expire after one hour.
+func NewIssuer() *Issuer {
+	return &Issuer{
+		Secret:   []byte("test-secret"),
+		Issuer:   DefaultIssuer,
+		Audience: DefaultAudience,
+		TTL:      time.Hour,
+	}
+}
+
+// Config returns a middleware config that accepts the tokens of the issuer.
+func (i *Issuer) Config() auth.Config {
+	return auth.Config{
+		HMACSecret: i.Secret,
+		Issuer:     i.Issuer,
+		Audience:   i.Audience,
+	}
+}
+
+// Token returns a signed token for the subject. The extra claims are added to the token and can
+// override the registered claims, e.g. {"exp": time.Now().Add(-time.Hour).Unix()} to create an expired token
+// or {"exp": nil} to create a token without expiry.
+// It panics if the token cannot be signed.
+func (i *Issuer) Token(subject string, extraClaims map[string]interface{}) string {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": subject,
+		"iss": i.Issuer,
+		"aud": i.Audience,
+		"iat": now.Unix(),
+		"exp": now.Add(i.TTL).Unix(),
+	}
+	for name, value := range extraClaims {
+		claims[name] = value
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.Secret)
+	if err != nil {
+		panic(err)
+	}
+	return token
+}
+
+// Headers returns the Authorization header with a token for the subject in the format used by
+// handlertest.Suite.DefaultHeaders and handlertest.Params.Headers.
+func (i *Issuer) Headers(subject string) map[string]string {
+	return map[string]string{echo.HeaderAuthorization: "Bearer " + i.Token(subject, nil)}
+}
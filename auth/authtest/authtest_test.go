@@ -0,0 +1,43 @@
+package authtest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/auth"
+	"github.com/fastbill/go-service-toolkit/v4/handlertest"
+)
+
+func TestIssuerWithHandlertest(t *testing.T) {
+	issuer := NewIssuer()
+	s := handlertest.Suite{
+		DefaultMiddleware: []echo.MiddlewareFunc{auth.Middleware(issuer.Config())},
+		DefaultHeaders:    issuer.Headers("user-1"),
+	}
+	handler := func(c echo.Context) error {
+		return c.String(http.StatusOK, auth.Subject(c))
+	}
+
+	rec, err := s.CallHandler(t, handler, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", rec.Body.String())
+
+	_, err = s.CallHandler(t, handler, &handlertest.Params{Headers: map[string]string{echo.HeaderAuthorization: ""}}, nil)
+	assert.Error(t, err)
+}
+
+func TestExpiredToken(t *testing.T) {
+	issuer := NewIssuer()
+	s := handlertest.Suite{DefaultMiddleware: []echo.MiddlewareFunc{auth.Middleware(issuer.Config())}}
+	token := issuer.Token("user-1", map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})
+
+	_, err := s.CallHandler(t, func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, &handlertest.Params{Headers: map[string]string{echo.HeaderAuthorization: "Bearer " + token}}, nil)
+	assert.EqualError(t, err, "401 - token is expired")
+}
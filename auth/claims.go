@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Keys under which the middleware stores the claims and the subject in the echo context.
+// SubjectKey can be used with server.KeyByContext to rate limit per user.
+const (
+	ClaimsKey  = "auth.claims"
+	SubjectKey = "auth.subject"
+)
+
+// Audience is the "aud" claim, it can either be a single string or a list of strings in the token.
+type Audience []string
+
+// UnmarshalJSON accepts a string or a list of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud needs to be a string or a list of strings")
+	}
+	*a = list
+	return nil
+}
+
+// Contains checks whether the audience contains the given value.
+func (a Audience) Contains(value string) bool {
+	for _, aud := range a {
+		if aud == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims contains the registered claims of the token. All claims, including custom ones, are available in Raw.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+	ID        string   `json:"jti"`
+
+	Raw map[string]interface{} `json:"-"`
+}
+
+// parseClaims converts the claims parsed by the jwt package.
+func parseClaims(raw map[string]interface{}) (*Claims, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, fmt.Errorf("claims are invalid: %w", err)
+	}
+	claims.Raw = raw
+	return claims, nil
+}
+
+// validate checks the expiry, issuer and audience. The leeway compensates for clock skew between servers.
+func (c *Claims) validate(config Config, now time.Time) error {
+	if c.ExpiresAt == 0 && !config.AllowMissingExpiry {
+		return errors.New("token has no expiry")
+	}
+	if c.ExpiresAt != 0 && now.Add(-config.Leeway).Unix() >= c.ExpiresAt {
+		return errors.New("token is expired")
+	}
+	if c.NotBefore != 0 && now.Add(config.Leeway).Unix() < c.NotBefore {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != 0 && now.Add(config.Leeway).Unix() < c.IssuedAt {
+		return errors.New("token was issued in the future")
+	}
+	if config.Issuer != "" && c.Issuer != config.Issuer {
+		return errors.New("token has an invalid issuer")
+	}
+	if config.Audience != "" && !c.Audience.Contains(config.Audience) {
+		return errors.New("token has an invalid audience")
+	}
+	return nil
+}
+
+// ClaimsFromContext returns the claims of the authenticated request.
+func ClaimsFromContext(c echo.Context) (*Claims, bool) {
+	claims, ok := c.Get(ClaimsKey).(*Claims)
+	return claims, ok
+}
+
+// Subject returns the subject of the authenticated request or an empty string.
+func Subject(c echo.Context) string {
+	subject, _ := c.Get(SubjectKey).(string)
+	return subject
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultJWKSCacheDuration = time.Hour
+	// minJWKSRefreshInterval limits how often the document is fetched because of unknown key IDs.
+	minJWKSRefreshInterval = time.Minute
+)
+
+// ErrUnknownKey is returned if the key set does not contain a key with the ID used in the token.
+var ErrUnknownKey = errors.New("unknown key ID")
+
+// KeyProvider returns the public key (*rsa.PublicKey or *ecdsa.PublicKey) for the key ID of a token.
+type KeyProvider interface {
+	Key(keyID string) (interface{}, error)
+}
+
+// jwk is a single key of a JSON Web Key Set, see RFC 7517. Only RSA and EC keys used for signatures are supported.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set document and returns the public keys by key ID.
+// Keys that are not used for signatures or have an unsupported type are skipped.
+func ParseJWKS(data []byte) (KeySet, error) {
+	document := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := KeySet{}
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		var (
+			publicKey interface{}
+			err       error
+		)
+		switch key.KeyType {
+		case "RSA":
+			publicKey, err = key.rsaPublicKey()
+		case "EC":
+			publicKey, err = key.ecdsaPublicKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key %q: %w", key.KeyID, err)
+		}
+		keys[key.KeyID] = publicKey
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	curves := map[string]elliptic.Curve{
+		"P-256": elliptic.P256(),
+		"P-384": elliptic.P384(),
+		"P-521": elliptic.P521(),
+	}
+	curve, ok := curves[k.Curve]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	if value == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// KeySet is a KeyProvider with a fixed set of keys.
+type KeySet map[string]interface{}
+
+// Key returns the key with the given ID.
+func (s KeySet) Key(keyID string) (interface{}, error) {
+	key, ok := s[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// NewKeySetFromFile loads the keys from a JSON Web Key Set file.
+func NewKeySetFromFile(path string) (KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// RemoteKeySet loads the keys from a JSON Web Key Set URL, e.g. of the identity provider, and caches them.
+// The document is fetched on first use, after the cache duration and if a token uses an unknown key ID
+// (at most once per minute), so rotated keys are picked up.
+type RemoteKeySet struct {
+	url           string
+	cacheDuration time.Duration
+	client        *http.Client
+	now           func() time.Time
+	// fetches ensures that concurrent calls wait for the same fetch instead of each fetching the document.
+	fetches singleflight.Group
+
+	mu          sync.RWMutex
+	keys        KeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+// NewRemoteKeySet creates a key set for the given URL. The default cache duration is one hour.
+func NewRemoteKeySet(url string, cacheDuration time.Duration) *RemoteKeySet {
+	if cacheDuration == 0 {
+		cacheDuration = defaultJWKSCacheDuration
+	}
+	return &RemoteKeySet{
+		url:           url,
+		cacheDuration: cacheDuration,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		now:           time.Now,
+	}
+}
+
+// Key returns the key with the given ID, the document is fetched if necessary. Cached keys are returned
+// without waiting while the document is fetched for other key IDs.
+func (s *RemoteKeySet) Key(keyID string) (interface{}, error) {
+	now := s.now()
+	key, found, refresh := s.cachedKey(keyID, now)
+	if !refresh {
+		// The cached key is still used if the last attempt to refresh the keys failed.
+		if found {
+			return key, nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	_, err, _ := s.fetches.Do(s.url, func() (interface{}, error) {
+		return nil, s.refresh(now)
+	})
+	if err != nil {
+		// Keep using the cached keys if the identity provider is temporarily not reachable.
+		if found {
+			return key, nil
+		}
+		return nil, err
+	}
+
+	key, found, _ = s.cachedKey(keyID, now)
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// cachedKey returns the cached key and whether the document needs to be fetched.
+func (s *RemoteKeySet) cachedKey(keyID string, now time.Time) (key interface{}, found bool, refresh bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, found = s.keys[keyID]
+	expired := now.Sub(s.fetchedAt) >= s.cacheDuration
+	canRefresh := now.Sub(s.attemptedAt) >= minJWKSRefreshInterval
+	return key, found, (!found || expired) && canRefresh
+}
+
+// refresh fetches the document and replaces the cached keys. The lock is not held while fetching.
+func (s *RemoteKeySet) refresh(now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.attemptedAt) < minJWKSRefreshInterval {
+		// Another call refreshed the keys in the meantime.
+		s.mu.Unlock()
+		return nil
+	}
+	s.attemptedAt = now
+	s.mu.Unlock()
+
+	keys, err := s.fetch()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.fetchedAt = now
+	return nil
+}
+
+func (s *RemoteKeySet) fetch() (KeySet, error) {
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
@@ -0,0 +1,134 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/auth"
+	"github.com/fastbill/go-service-toolkit/v4/auth/authtest"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+func TestMiddlewareHMAC(t *testing.T) {
+	issuer := authtest.NewIssuer()
+	e := newTestServer(t)
+	e.Use(auth.Middleware(issuer.Config()))
+	e.GET("/", func(c echo.Context) error {
+		claims, ok := auth.ClaimsFromContext(c)
+		require.True(t, ok)
+		assert.Equal(t, "user-1", claims.Subject)
+		assert.True(t, claims.Audience.Contains(authtest.DefaultAudience))
+		assert.Equal(t, "admin", claims.Raw["role"])
+		assert.Equal(t, "user-1", auth.Subject(c))
+		assert.Equal(t, observance.Fields{auth.SubjectLogField: "user-1"}, observance.LogFieldsFromContext(c.Request().Context()))
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+		description   string
+	}{
+		{"valid", "Bearer " + issuer.Token("user-1", map[string]interface{}{"role": "admin"}), http.StatusNoContent, ""},
+		{"audience list", "Bearer " + issuer.Token("user-1", map[string]interface{}{"role": "admin", "aud": []string{"other", authtest.DefaultAudience}}), http.StatusNoContent, ""},
+		{"missing", "", http.StatusUnauthorized, "bearer token missing"},
+		{"wrong scheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, "bearer token missing"},
+		{"malformed", "Bearer abc", http.StatusUnauthorized, "token is invalid: token contains an invalid number of segments"},
+		{"wrong secret", "Bearer " + (&authtest.Issuer{Secret: []byte("other"), Issuer: authtest.DefaultIssuer, Audience: authtest.DefaultAudience, TTL: time.Hour}).Token("user-1", nil), http.StatusUnauthorized, "token is invalid: signature is invalid"},
+		{"expired", "Bearer " + issuer.Token("user-1", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized, "token is expired"},
+		{"no expiry", "Bearer " + issuer.Token("user-1", map[string]interface{}{"exp": nil}), http.StatusUnauthorized, "token has no expiry"},
+		{"not valid yet", "Bearer " + issuer.Token("user-1", map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()}), http.StatusUnauthorized, "token is not valid yet"},
+		{"wrong issuer", "Bearer " + issuer.Token("user-1", map[string]interface{}{"iss": "other"}), http.StatusUnauthorized, "token has an invalid issuer"},
+		{"wrong audience", "Bearer " + issuer.Token("user-1", map[string]interface{}{"aud": "other"}), http.StatusUnauthorized, "token has an invalid audience"},
+		{"none algorithm", "Bearer " + noneToken(t), http.StatusUnauthorized, "token is invalid: signing method none is invalid"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, test.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.status, rec.Code)
+			if test.description != "" {
+				assert.Equal(t, `Bearer error="invalid_token", error_description="`+test.description+`"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+		})
+	}
+}
+
+func TestMiddlewareLeewayAndSkipper(t *testing.T) {
+	issuer := authtest.NewIssuer()
+	config := issuer.Config()
+	config.Leeway = time.Minute
+	config.AllowMissingExpiry = true
+	config.Skipper = func(c echo.Context) bool {
+		return c.Path() == "/health"
+	}
+
+	e := newTestServer(t)
+	e.Use(auth.Middleware(config))
+	e.GET("/", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	e.GET("/health", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	for name, token := range map[string]string{
+		"within leeway": issuer.Token("user-1", map[string]interface{}{"exp": time.Now().Add(-30 * time.Second).Unix()}),
+		"no expiry":     issuer.Token("user-1", map[string]interface{}{"exp": nil}),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code, name)
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	assert.Panics(t, func() { auth.Middleware(auth.Config{}) })
+}
+
+func TestSubjectIsLogged(t *testing.T) {
+	issuer := authtest.NewIssuer()
+	testLogger := observance.NewTestLogger()
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: testLogger})
+	require.NoError(t, err)
+	e.GET("/", func(c echo.Context) error {
+		return errors.New("testError")
+	}, auth.Middleware(issuer.Config()))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+issuer.Token("user-1", nil))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "testError", testLogger.LastEntry().Message)
+	assert.Equal(t, "user-1", testLogger.LastEntry().Data[auth.SubjectLogField])
+}
+
+func newTestServer(t *testing.T) *echo.Echo {
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	return e
+}
+
+func noneToken(t *testing.T) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"sub": "user-1"}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	return token
+}
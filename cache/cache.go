@@ -38,6 +38,21 @@ type Cache interface {
 	TTL(key string) (time.Duration, error)
 }
 
+// Locker is implemented by caches that can be used for locks, e.g. RedisClient.
+// It is not part of the Cache interface, so existing implementations of Cache are not affected.
+type Locker interface {
+	// SetNX sets the value only if the key does not exist yet and returns true if it was set.
+	SetNX(key string, value string, expiration time.Duration) (bool, error)
+	// CompareAndDelete deletes the key only if it still holds the given value and returns true if it was deleted.
+	CompareAndDelete(key string, value string) (bool, error)
+}
+
+// Pinger is implemented by caches that can check whether the server is reachable, e.g. RedisClient.
+// It is not part of the Cache interface, so existing implementations of Cache are not affected.
+type Pinger interface {
+	Ping() error
+}
+
 // RedisClient wraps the REDIS client to provide an implementation of the Cache interface.
 // It allows defining a prefix that is applied to the key for all operations (optional).
 type RedisClient struct {
@@ -78,6 +93,34 @@ func (r *RedisClient) Set(key string, value string, expiration time.Duration) er
 	return r.Redis.Set(ctx, r.prefixedKey(key), value, expiration).Err()
 }
 
+// SetNX saves a key value pair to REDIS only if the key does not exist yet.
+// If the client was set up with a prefix it will be added in front of the key.
+// Redis `SET key value NX [expiration]` command. It returns true if the value was set.
+// Zero expiration means the key has no expiration time.
+func (r *RedisClient) SetNX(key string, value string, expiration time.Duration) (bool, error) {
+	return r.Redis.SetNX(ctx, r.prefixedKey(key), value, expiration).Result()
+}
+
+// compareAndDeleteScript deletes the key only if it holds the value in ARGV[1].
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// CompareAndDelete deletes a key from REDIS only if it still holds the given value, e.g. to release a lock only
+// if it was not taken over by someone else after it expired. It returns true if the key was deleted.
+// If the client was set up with a prefix it will be added in front of the key.
+func (r *RedisClient) CompareAndDelete(key string, value string) (bool, error) {
+	result, err := r.RunScript(compareAndDeleteScript, []string{key}, value)
+	if err != nil {
+		return false, err
+	}
+	deleted, _ := result.(int64)
+	return deleted == 1, nil
+}
+
 // Get retrieves a value from REDIS.
 // If the client was set up with a prefix it will be added in front of the key.
 // If the value was not found ErrNotFound will be returned.
@@ -184,6 +227,28 @@ func (r *RedisClient) TTL(key string) (time.Duration, error) {
 	return result, nil
 }
 
+// Ping checks whether the REDIS server is reachable.
+func (r *RedisClient) Ping() error {
+	return r.Redis.Ping(ctx).Err()
+}
+
+// RunScript executes a Lua script in REDIS via EVALSHA and falls back to EVAL if the script was not loaded yet.
+// If the client was set up with a prefix it will be added in front of all keys.
+// Unlike the other methods, it is not part of the Cache interface.
+func (r *RedisClient) RunScript(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	return r.RunScriptContext(ctx, script, keys, args...)
+}
+
+// RunScriptContext is like RunScript but uses the given context for the call, so it can be cancelled
+// together with the request.
+func (r *RedisClient) RunScriptContext(ctx context.Context, script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	prefixedKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefixedKeys = append(prefixedKeys, r.prefixedKey(key))
+	}
+	return script.Run(ctx, r.Redis, prefixedKeys, args...).Result()
+}
+
 // prefixedKey adds the prefix in front of the key separated with ":".
 // If no prefix was provided for the client than the key is returned as is.
 func (r *RedisClient) prefixedKey(key string) string {
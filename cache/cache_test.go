@@ -1,10 +1,12 @@
 package cache
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	goRedis "github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,6 +24,8 @@ func TestNewRedis(t *testing.T) {
 	client, err := NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
 	assert.NoError(t, err)
 	assert.Implements(t, (*Cache)(nil), client)
+	assert.Implements(t, (*Pinger)(nil), client)
+	assert.Implements(t, (*Locker)(nil), client)
 }
 
 func TestPrefix(t *testing.T) {
@@ -65,6 +69,22 @@ func TestSet(t *testing.T) {
 	})
 }
 
+func TestSetNX(t *testing.T) {
+	withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
+		ok, err := client.SetNX("someKey", "someValue", 10*time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = client.SetNX("someKey", "otherValue", 10*time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		redis.CheckGet(t, "testPrefix:someKey", "someValue")
+
+		redis.FastForward(11 * time.Minute)
+		assert.False(t, redis.Exists("testPrefix:someKey"))
+	})
+}
+
 func TestGet(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
@@ -299,6 +319,57 @@ func TestTTL(t *testing.T) {
 	})
 }
 
+func TestCompareAndDelete(t *testing.T) {
+	withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
+		require.NoError(t, client.Set("lock", "token1", 0))
+
+		deleted, err := client.CompareAndDelete("lock", "token2")
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+		assert.True(t, redis.Exists("testPrefix:lock"))
+
+		deleted, err = client.CompareAndDelete("lock", "token1")
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+		assert.False(t, redis.Exists("testPrefix:lock"))
+
+		deleted, err = client.CompareAndDelete("lock", "token1")
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+	})
+}
+
+func TestPing(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
+			assert.NoError(t, client.Ping())
+		})
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
+			redis.Close()
+			assert.Error(t, client.Ping())
+		})
+	})
+}
+
+func TestRunScript(t *testing.T) {
+	withRedis(t, func(redis *miniredis.Miniredis, client *RedisClient) {
+		script := goRedis.NewScript(`return redis.call('SET', KEYS[1], ARGV[1])`)
+		result, err := client.RunScript(script, []string{"someKey"}, "someValue")
+		assert.NoError(t, err)
+		assert.Equal(t, "OK", result)
+		redis.CheckGet(t, "testPrefix:someKey", "someValue")
+
+		cancelledCtx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = client.RunScriptContext(cancelledCtx, script, []string{"someKey"}, "otherValue")
+		assert.ErrorIs(t, err, context.Canceled)
+		redis.CheckGet(t, "testPrefix:someKey", "someValue")
+	})
+}
+
 func withRedis(t *testing.T, fn func(redis *miniredis.Miniredis, client *RedisClient)) {
 	redis, err := miniredis.Run()
 	assert.NoError(t, err, "error in test setup")
@@ -18,6 +18,13 @@ func (m *Cache) Close() error {
 	return args.Error(0)
 }
 
+// CompareAndDelete is a mock implementation of cache.Locker#CompareAndDelete.
+func (m *Cache) CompareAndDelete(key string, value string) (bool, error) {
+	args := m.Called(key, value)
+
+	return args.Bool(0), args.Error(1)
+}
+
 // Del is a mock implementation of cache.Cache#Del.
 func (m *Cache) Del(key string) error {
 	args := m.Called(key)
@@ -60,6 +67,13 @@ func (m *Cache) Incr(key string) (int64, error) {
 	return args.Get(0).(int64), args.Error(1)
 }
 
+// Ping is a mock implementation of cache.Pinger#Ping.
+func (m *Cache) Ping() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
 // Prefix is a mock implementation of cache.Cache#Prefix.
 func (m *Cache) Prefix() string {
 	args := m.Called()
@@ -74,6 +88,13 @@ func (m *Cache) Set(key string, value string, expiration time.Duration) error {
 	return args.Error(0)
 }
 
+// SetNX is a mock implementation of cache.Locker#SetNX.
+func (m *Cache) SetNX(key string, value string, expiration time.Duration) (bool, error) {
+	args := m.Called(key, value, expiration)
+
+	return args.Bool(0), args.Error(1)
+}
+
 // SetBool is a mock implementation of cache.Cache#SetBool.
 func (m *Cache) SetBool(key string, value bool, expiration time.Duration) error {
 	args := m.Called(key, value, expiration)
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/labstack/echo/v4"
-
 	toolkit "github.com/fastbill/go-service-toolkit/v4"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/server"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
 )
 
 // User holds all basic user information.
@@ -36,6 +40,15 @@ func main() {
 	obs := toolkit.MustNewObs(obsConfig)
 	defer obs.PanicRecover()
 
+	// Set up the graceful shutdown of all components.
+	shutdownManager := toolkit.NewShutdownManager(toolkit.ShutdownConfig{
+		Timeout:    20 * time.Second,
+		DrainDelay: 5 * time.Second,
+	}, obs.Logger)
+	if closer, ok := obs.Metrics.(io.Closer); ok {
+		shutdownManager.Register("metrics", shutdown.OrderMetrics, shutdown.Closer(closer))
+	}
+
 	// Set up DB connection and run migrations.
 	dbConfig := toolkit.DBConfig{
 		Dialect:  os.Getenv("DB_DIALECT"),
@@ -46,57 +59,48 @@ func main() {
 		Name:     os.Getenv("DATABASE_NAME"),
 	}
 	db := toolkit.MustSetupDB(dbConfig, obs.Logger)
-	defer func() {
-		if err := toolkit.CloseDatabase(db); err != nil {
-			obs.Logger.WithError(err).Error("failed to close DB connection")
-		}
-	}()
+	shutdownManager.Register("database", shutdown.OrderConnections, shutdown.Func(func() error {
+		return toolkit.CloseDatabase(db)
+	}))
 
 	toolkit.MustEnsureDBMigrations("migrations", dbConfig)
 
 	// Set up REDIS cache.
 	cache := toolkit.MustNewCache(os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT"), "testPrefix")
-	defer func() {
-		if err := cache.Close(); err != nil {
-			obs.Logger.WithError(err).Error("failed to close REDIS connection")
-		}
-	}()
+	shutdownManager.Register("cache", shutdown.OrderConnections, shutdown.Closer(cache))
 
 	// Set up the server.
-	e, connectionsClosed := toolkit.MustNewServer(obs, "")
+	e, _ := toolkit.MustNewServerWithOptions(obs, server.WithShutdownManager(shutdownManager))
 
 	// Set up a routes and handlers.
-	e.POST("/users", func(c echo.Context) error {
-		obs.Logger.Info("incoming request to create new user")
-
-		newUser := &User{}
-		err := c.Bind(newUser)
-		if err != nil {
-			obs.Logger.WithError(err).Warn("invalid request")
-			return c.JSON(http.StatusBadRequest, map[string]string{"msg": err.Error()})
-		}
+	e.POST("/users", server.Handle(func(ctx context.Context, newUser User) (User, error) {
+		// The request specific observance adds the URL, method and request ID to the logs.
+		reqObs := observance.ObsFromContext(ctx)
+		reqObs.Logger.Info("incoming request to create new user")
 
-		if err = db.Save(newUser).Error; err != nil {
-			obs.Logger.WithError(err).Error("failed to save user to DB")
-			return c.JSON(http.StatusInternalServerError, map[string]string{"msg": err.Error()})
+		if err := db.WithContext(ctx).Save(&newUser).Error; err != nil {
+			return User{}, fmt.Errorf("failed to save user to DB: %w", err)
 		}
 
 		// Nonsense cache usage example
-		err = cache.SetJSON("latestNewUser", newUser, 0)
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{"msg": err.Error()})
+		if err := cache.SetJSON("latestNewUser", newUser, 0); err != nil {
+			return User{}, err
 		}
 
-		return c.NoContent(http.StatusCreated)
-	})
+		return newUser, nil
+	}, server.WithStatus(http.StatusCreated)))
 
 	// Start the server.
 	port := os.Getenv("PORT")
 	obs.Logger.WithField("port", port).Info("server running")
 	err := e.Start(":" + port)
-	if err != nil {
-		obs.Logger.Warn(err)
+	if err != nil && err != http.ErrServerClosed {
+		obs.Logger.WithError(err).Error("failed to start server")
+		shutdownManager.Shutdown()
 	}
 
-	<-connectionsClosed // Wait for the graceful shutdown to finish.
+	// Wait for the graceful shutdown of all components to finish.
+	if err := shutdownManager.Wait(); err != nil {
+		obs.Logger.WithError(err).Error("graceful shutdown failed")
+	}
 }
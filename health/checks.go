@@ -0,0 +1,67 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+)
+
+// healthCheckKey is read by CacheCheck for caches that do not implement cache.Pinger.
+const healthCheckKey = "health:check"
+
+// Checker checks whether a dependency of the service works as expected.
+type Checker interface {
+	// Name identifies the check in the report.
+	Name() string
+	// Check returns an error if the dependency does not work. It should respect the deadline of the context.
+	Check(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checkFunc) Name() string {
+	return c.name
+}
+
+func (c *checkFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewCheck creates a checker with the given name for an arbitrary dependency.
+func NewCheck(name string, fn func(ctx context.Context) error) Checker {
+	return &checkFunc{name: name, fn: fn}
+}
+
+// DBCheck creates a checker named "database" that pings the database used by GORM.
+func DBCheck(db *gorm.DB) Checker {
+	return NewCheck("database", func(ctx context.Context) error {
+		dbConn, err := db.DB()
+		if err != nil {
+			return fmt.Errorf("failed to retrieve DB connection: %w", err)
+		}
+		return dbConn.PingContext(ctx)
+	})
+}
+
+// CacheCheck creates a checker named "cache" that pings the cache (e.g. REDIS PING) if it implements cache.Pinger.
+// Other caches are checked by reading a key, a missing key counts as success.
+// The cache methods do not accept a context, the timeout is still enforced by the health checks.
+func CacheCheck(c cache.Cache) Checker {
+	return NewCheck("cache", func(ctx context.Context) error {
+		if pinger, ok := c.(cache.Pinger); ok {
+			return pinger.Ping()
+		}
+		_, err := c.Get(healthCheckKey)
+		if errors.Is(err, cache.ErrNotFound) {
+			return nil
+		}
+		return err
+	})
+}
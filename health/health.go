@@ -0,0 +1,267 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/sync/singleflight"
+)
+
+// Routes under which the health endpoints are registered.
+const (
+	LivenessRoute  = "/healthz"
+	ReadinessRoute = "/readyz"
+)
+
+// Possible values for the status of a report or a single check.
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+const (
+	defaultTimeout = 5 * time.Second
+	shutdownCheck  = "shutdown"
+)
+
+// ErrShuttingDown is reported by the readiness endpoint once the graceful shutdown has started.
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// Config contains the settings for the health endpoints.
+type Config struct {
+	// Timeout is the maximum duration of a single check. The default is 5 seconds.
+	Timeout time.Duration
+	// CacheDuration defines how long the result of a check is reused before the check is executed again (optional).
+	// This protects the dependencies in case the endpoints are called very frequently.
+	CacheDuration time.Duration
+	// ExposeErrors adds the error messages of failing checks to the responses of the endpoints. By default only
+	// the name, status and duration of each check are sent because the errors can contain internal details.
+	// Liveness and Readiness always return the errors.
+	ExposeErrors bool
+}
+
+// Report is the response body of the health endpoints.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult contains the outcome of a single check.
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Health runs the registered checks and provides the liveness and readiness endpoints.
+// Liveness checks should only fail if the service is broken and needs to be restarted.
+// Readiness checks should fail if the service can currently not handle requests, e.g. because the database is not reachable.
+type Health struct {
+	config          Config
+	livenessChecks  []*cachedCheck
+	readinessChecks []*cachedCheck
+
+	mu           sync.RWMutex
+	shuttingDown bool
+}
+
+// New creates a new Health instance without any checks.
+func New(config Config) *Health {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	return &Health{config: config}
+}
+
+// AddLivenessCheck adds checks that are executed for the liveness endpoint.
+func (h *Health) AddLivenessCheck(checks ...Checker) {
+	for _, check := range checks {
+		h.livenessChecks = append(h.livenessChecks, &cachedCheck{checker: check})
+	}
+}
+
+// AddReadinessCheck adds checks that are executed for the readiness endpoint.
+func (h *Health) AddReadinessCheck(checks ...Checker) {
+	for _, check := range checks {
+		h.readinessChecks = append(h.readinessChecks, &cachedCheck{checker: check})
+	}
+}
+
+// Register adds the liveness and readiness endpoints to the echo server.
+func (h *Health) Register(echoServer *echo.Echo, middleware ...echo.MiddlewareFunc) {
+	echoServer.GET(LivenessRoute, h.LivenessHandler, middleware...)
+	echoServer.GET(ReadinessRoute, h.ReadinessHandler, middleware...)
+}
+
+// SetShuttingDown lets the readiness endpoint fail from now on so the load balancer stops sending new requests.
+func (h *Health) SetShuttingDown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shuttingDown = true
+}
+
+func (h *Health) isShuttingDown() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.shuttingDown
+}
+
+// Liveness runs the liveness checks.
+func (h *Health) Liveness(ctx context.Context) Report {
+	return h.run(ctx, h.livenessChecks)
+}
+
+// Readiness runs the readiness checks. It fails once the graceful shutdown has started.
+func (h *Health) Readiness(ctx context.Context) Report {
+	report := h.run(ctx, h.readinessChecks)
+	if h.isShuttingDown() {
+		report.Status = StatusFailing
+		report.Checks[shutdownCheck] = CheckResult{
+			Status:    StatusFailing,
+			Error:     ErrShuttingDown.Error(),
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		}
+	}
+	return report
+}
+
+// LivenessHandler is the echo handler for the liveness endpoint.
+// It responds with 200 if all checks succeeded and 503 otherwise.
+func (h *Health) LivenessHandler(c echo.Context) error {
+	return h.writeReport(c, h.Liveness(c.Request().Context()))
+}
+
+// ReadinessHandler is the echo handler for the readiness endpoint.
+// It responds with 200 if all checks succeeded and 503 otherwise.
+func (h *Health) ReadinessHandler(c echo.Context) error {
+	return h.writeReport(c, h.Readiness(c.Request().Context()))
+}
+
+func (h *Health) writeReport(c echo.Context, report Report) error {
+	if !h.config.ExposeErrors {
+		for name, result := range report.Checks {
+			result.Error = ""
+			report.Checks[name] = result
+		}
+	}
+	if report.Status != StatusOK {
+		return c.JSON(http.StatusServiceUnavailable, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// run executes all checks in parallel and combines the results.
+func (h *Health) run(ctx context.Context, checks []*cachedCheck) Report {
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(checks)),
+	}
+
+	results := make([]CheckResult, len(checks))
+	wg := sync.WaitGroup{}
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *cachedCheck) {
+			defer wg.Done()
+			results[i] = check.run(ctx, h.config)
+		}(i, check)
+	}
+	wg.Wait()
+
+	for i, check := range checks {
+		report.Checks[check.checker.Name()] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// cachedCheck wraps a checker and stores its last result.
+type cachedCheck struct {
+	checker Checker
+	// runs ensures concurrent requests do not execute the same check multiple times.
+	runs singleflight.Group
+
+	mu         sync.Mutex
+	lastResult *CheckResult
+}
+
+// run executes the check unless a cached result can be used. Requests that arrive while the check is running wait
+// for its result instead of starting it again. They stop waiting when their context is done.
+func (c *cachedCheck) run(ctx context.Context, config Config) CheckResult {
+	if result, ok := c.cachedResult(config.CacheDuration); ok {
+		return result
+	}
+
+	resultChan := c.runs.DoChan("", func() (interface{}, error) {
+		// The check is shared by all waiting requests, so it must not be cancelled when the request that
+		// started it is done.
+		result := execute(context.Background(), c.checker, config.Timeout)
+		c.mu.Lock()
+		c.lastResult = &result
+		c.mu.Unlock()
+		return result, nil
+	})
+
+	select {
+	case shared := <-resultChan:
+		return shared.Val.(CheckResult)
+	case <-ctx.Done():
+		return CheckResult{
+			Status:    StatusFailing,
+			Error:     ctx.Err().Error(),
+			Duration:  "0s",
+			CheckedAt: time.Now(),
+		}
+	}
+}
+
+func (c *cachedCheck) cachedResult(cacheDuration time.Duration) (CheckResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastResult != nil && time.Since(c.lastResult.CheckedAt) < cacheDuration {
+		return *c.lastResult, true
+	}
+	return CheckResult{}, false
+}
+
+func execute(ctx context.Context, checker Checker, timeout time.Duration) CheckResult {
+	start := time.Now()
+	err := runWithTimeout(ctx, checker, timeout)
+	result := CheckResult{
+		Status:    StatusOK,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// runWithTimeout returns as soon as the timeout is reached, even if the check itself does not respect the context.
+func runWithTimeout(ctx context.Context, checker Checker, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- checker.Check(ctx)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check did not finish in time: %w", ctx.Err())
+	}
+}
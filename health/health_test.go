@@ -0,0 +1,203 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+)
+
+func TestEndpoints(t *testing.T) {
+	okCheck := NewCheck("ok", func(ctx context.Context) error { return nil })
+	failingCheck := NewCheck("failing", func(ctx context.Context) error { return errors.New("testError") })
+
+	t.Run("healthy", func(t *testing.T) {
+		h := New(Config{})
+		h.AddLivenessCheck(okCheck)
+		h.AddReadinessCheck(okCheck)
+
+		code, report := call(t, h, LivenessRoute)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["ok"].Status)
+
+		code, report = call(t, h, ReadinessRoute)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, StatusOK, report.Status)
+	})
+
+	t.Run("no checks", func(t *testing.T) {
+		h := New(Config{})
+		code, report := call(t, h, LivenessRoute)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, Report{Status: StatusOK}, report)
+	})
+
+	t.Run("failing readiness check", func(t *testing.T) {
+		h := New(Config{})
+		h.AddReadinessCheck(okCheck, failingCheck)
+
+		code, _ := call(t, h, LivenessRoute)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, report := call(t, h, ReadinessRoute)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.Equal(t, StatusOK, report.Checks["ok"].Status)
+		assert.Equal(t, StatusFailing, report.Checks["failing"].Status)
+		assert.Empty(t, report.Checks["failing"].Error, "errors are not exposed by default")
+		assert.Equal(t, "testError", h.Readiness(context.Background()).Checks["failing"].Error)
+	})
+
+	t.Run("errors exposed", func(t *testing.T) {
+		h := New(Config{ExposeErrors: true})
+		h.AddReadinessCheck(failingCheck)
+
+		code, report := call(t, h, ReadinessRoute)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "testError", report.Checks["failing"].Error)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		h := New(Config{Timeout: 10 * time.Millisecond, ExposeErrors: true})
+		h.AddReadinessCheck(NewCheck("slow", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}))
+
+		start := time.Now()
+		code, report := call(t, h, ReadinessRoute)
+		assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, report.Checks["slow"].Error, "did not finish in time")
+	})
+
+	t.Run("cached results", func(t *testing.T) {
+		var calls int64
+		h := New(Config{CacheDuration: time.Hour})
+		h.AddReadinessCheck(NewCheck("counting", func(ctx context.Context) error {
+			atomic.AddInt64(&calls, 1)
+			return nil
+		}))
+
+		call(t, h, ReadinessRoute)
+		call(t, h, ReadinessRoute)
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("concurrent requests share a running check", func(t *testing.T) {
+		var calls int64
+		release := make(chan struct{})
+		h := New(Config{})
+		h.AddReadinessCheck(NewCheck("blocking", func(ctx context.Context) error {
+			atomic.AddInt64(&calls, 1)
+			<-release
+			return nil
+		}))
+
+		statuses := make(chan string, 5)
+		for i := 0; i < 5; i++ {
+			go func() {
+				statuses <- h.Readiness(context.Background()).Status
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, StatusOK, <-statuses)
+		}
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("waiting stops with the request", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		h := New(Config{})
+		h.AddReadinessCheck(NewCheck("blocking", func(ctx context.Context) error {
+			<-release
+			return nil
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		report := h.Readiness(ctx)
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["blocking"].Error)
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		h := New(Config{})
+		h.AddReadinessCheck(okCheck)
+		h.SetShuttingDown()
+
+		code, _ := call(t, h, LivenessRoute)
+		assert.Equal(t, http.StatusOK, code)
+
+		code, report := call(t, h, ReadinessRoute)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, StatusOK, report.Checks["ok"].Status)
+		assert.Equal(t, StatusFailing, report.Checks["shutdown"].Status)
+		assert.Equal(t, ErrShuttingDown.Error(), h.Readiness(context.Background()).Checks["shutdown"].Error)
+	})
+}
+
+func TestCacheCheck(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err, "error in test setup")
+	defer redisServer.Close()
+	client, err := cache.NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
+	require.NoError(t, err, "error in test setup")
+
+	check := CacheCheck(client)
+	assert.Equal(t, "cache", check.Name())
+	assert.NoError(t, check.Check(context.Background()))
+
+	t.Run("cache without Ping", func(t *testing.T) {
+		check := CacheCheck(struct{ cache.Cache }{client})
+		assert.NoError(t, check.Check(context.Background()))
+
+		require.NoError(t, redisServer.Set("testPrefix:health:check", "1"))
+		assert.NoError(t, check.Check(context.Background()))
+	})
+
+	redisServer.Close()
+	assert.Error(t, check.Check(context.Background()))
+	assert.Error(t, CacheCheck(struct{ cache.Cache }{client}).Check(context.Background()))
+}
+
+func TestDBCheck(t *testing.T) {
+	db, err := gorm.Open(postgres.Open("host=127.0.0.1 port=1 user=test dbname=test sslmode=disable connect_timeout=1"), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err, "error in test setup")
+
+	check := DBCheck(db)
+	assert.Equal(t, "database", check.Name())
+	assert.Error(t, check.Check(context.Background()))
+}
+
+func call(t *testing.T, h *Health, route string) (int, Report) {
+	e := echo.New()
+	h.Register(e)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route, nil))
+
+	report := Report{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
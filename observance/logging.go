@@ -24,6 +24,17 @@ type Logger interface {
 	SetOutput(w io.Writer)
 }
 
+// LevelSetter is implemented by loggers that allow to change the log level at runtime.
+type LevelSetter interface {
+	SetLevel(level string) error
+}
+
+// Flusher is implemented by loggers that send log entries asynchronously, e.g. to Sentry.
+// Flush needs to be called before the program exits, otherwise the entries might get lost.
+type Flusher interface {
+	Flush()
+}
+
 // Fields is a type alias to ease reading.
 type Fields = map[string]interface{}
 
@@ -31,37 +42,57 @@ type Fields = map[string]interface{}
 type LogrusLogger struct {
 	basicLogger *logrus.Logger
 	logger      *logrus.Entry
+	sampler     *sampler
 }
 
 // Level returns the log level that was set for the logger.
 // Only entries with that level or above with be logged.
 func (l *LogrusLogger) Level() string {
-	return l.basicLogger.Level.String()
+	return l.basicLogger.GetLevel().String()
+}
+
+// SetLevel changes the log level at runtime.
+// The change applies to all loggers that were derived from the same logger, e.g. via WithField.
+func (l *LogrusLogger) SetLevel(level string) error {
+	logrusLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.basicLogger.SetLevel(logrusLevel)
+	return nil
 }
 
 // Trace writes a log entry with level "trace".
 func (l *LogrusLogger) Trace(msg interface{}) {
-	l.logger.Trace(msg)
+	l.log(logrus.TraceLevel, msg)
 }
 
 // Debug writes a log entry with level "debug".
 func (l *LogrusLogger) Debug(msg interface{}) {
-	l.logger.Debug(msg)
+	l.log(logrus.DebugLevel, msg)
 }
 
 // Info writes a log entry with level "info".
 func (l *LogrusLogger) Info(msg interface{}) {
-	l.logger.Info(msg)
+	l.log(logrus.InfoLevel, msg)
 }
 
 // Warn writes a log entry with level "warning".
 func (l *LogrusLogger) Warn(msg interface{}) {
-	l.logger.Warn(msg)
+	l.log(logrus.WarnLevel, msg)
 }
 
 // Error writes a log entry with level "error".
 func (l *LogrusLogger) Error(msg interface{}) {
-	l.logger.Error(msg)
+	l.log(logrus.ErrorLevel, msg)
+}
+
+// log writes the entry if the level is enabled and the entry was not dropped by the sampling.
+func (l *LogrusLogger) log(level logrus.Level, msg interface{}) {
+	if !l.basicLogger.IsLevelEnabled(level) || !l.sampler.allow(level, msg) {
+		return
+	}
+	l.logger.Log(level, msg)
 }
 
 // WithField adds an additional field for logging.
@@ -69,6 +100,7 @@ func (l *LogrusLogger) WithField(key string, value interface{}) Logger {
 	return &LogrusLogger{
 		basicLogger: l.basicLogger,
 		logger:      l.logger.WithField(key, value),
+		sampler:     l.sampler,
 	}
 }
 
@@ -78,6 +110,7 @@ func (l *LogrusLogger) WithFields(fields Fields) Logger {
 	return &LogrusLogger{
 		basicLogger: l.basicLogger,
 		logger:      l.logger.WithFields(logrus.Fields(fields)),
+		sampler:     l.sampler,
 	}
 }
 
@@ -86,14 +119,34 @@ func (l *LogrusLogger) WithError(err error) Logger {
 	return &LogrusLogger{
 		basicLogger: l.basicLogger,
 		logger:      l.logger.WithError(err),
+		sampler:     l.sampler,
 	}
 }
 
+// SetMeasurer sets the Measurer that is used to count the log entries that were dropped due to sampling.
+func (l *LogrusLogger) SetMeasurer(measurer Measurer) {
+	l.sampler.setMeasurer(measurer)
+}
+
+// Output returns the writer the logs are written to.
+func (l *LogrusLogger) Output() io.Writer {
+	return l.basicLogger.Out
+}
+
 // SetOutput changes where the logs are written to. The default is Stdout.
 func (l *LogrusLogger) SetOutput(w io.Writer) {
 	l.basicLogger.SetOutput(w)
 }
 
+// Flush waits until the log entries were sent to Sentry, at most 5 seconds.
+func (l *LogrusLogger) Flush() {
+	for _, hook := range l.basicLogger.Hooks[logrus.ErrorLevel] {
+		if sentryHook, ok := hook.(*sentryHook); ok {
+			sentryHook.Flush()
+		}
+	}
+}
+
 // NewLogrus creates a Logrus logger that fulfils the Logger interface with Sentry integration.
 // All log messages will contain app name, pid and hostname/containerID.
 func NewLogrus(config Config) (Logger, error) {
@@ -119,7 +172,14 @@ func NewLogrus(config Config) (Logger, error) {
 		basicLogger.Formatter = &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
 	}
 
-	if config.SentryURL != "" {
+	redactor, err := newRedactor(config.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	// The redaction hook is added first so the other hooks only receive the redacted fields.
+	basicLogger.Hooks.Add(&redactionHook{redactor: redactor})
+
+	if config.SentryURL != "" || config.SentryTransport != nil {
 		levelsToSendToSentry := []logrus.Level{
 			logrus.PanicLevel,
 			logrus.FatalLevel,
@@ -128,10 +188,14 @@ func NewLogrus(config Config) (Logger, error) {
 
 		sentryOpts := sentryOptions{
 			Dsn:              config.SentryURL,
+			Transport:        config.SentryTransport,
 			AttachStacktrace: true,
 			BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
 				for i := range event.Exception {
-					event.Exception[i].Stacktrace.Frames = filterVendorFrames(event.Exception[i].Stacktrace.Frames)
+					// Only wrapped errors that recorded a stack trace have one.
+					if event.Exception[i].Stacktrace != nil {
+						event.Exception[i].Stacktrace.Frames = filterVendorFrames(event.Exception[i].Stacktrace.Frames)
+					}
 				}
 				// Remove the list of all packages of the service. It just spams Sentry.
 				event.Modules = make(map[string]string)
@@ -157,10 +221,15 @@ func NewLogrus(config Config) (Logger, error) {
 		"hostname": hostname,
 	})
 
-	return &LogrusLogger{
+	logrusLogger := &LogrusLogger{
 		basicLogger: basicLogger,
 		logger:      logger,
-	}, nil
+	}
+	if config.Sampling.enabled() {
+		logrusLogger.sampler = newSampler(config.Sampling)
+	}
+
+	return logrusLogger, nil
 }
 
 // filterVendorFrames removes frames that belong to the vendor folder from the stack trace.
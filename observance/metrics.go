@@ -1,10 +1,13 @@
 package observance
 
 import (
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/client_golang/prometheus/push"
 )
 
@@ -20,9 +23,13 @@ type Measurer interface {
 type PrometheusMetrics struct {
 	registry *prometheus.Registry
 	pusher   *push.Pusher
+	logger   Logger
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu       sync.Mutex
 	gauges   map[string]prometheus.Gauge
 	counters map[string]prometheus.Counter
-	logger   Logger
 }
 
 // NewPrometheusMetrics creates a new metrics instance to collect metrics.
@@ -33,7 +40,8 @@ func NewPrometheusMetrics(url, appName string, flushInterval time.Duration, logg
 		Grouping("instance", hostName()).
 		Gatherer(registry)
 
-	go continuouslyPush(pusher, flushInterval, logger)
+	stop := make(chan struct{})
+	go continuouslyPush(pusher, flushInterval, logger, stop)
 
 	return &PrometheusMetrics{
 		registry: registry,
@@ -41,55 +49,79 @@ func NewPrometheusMetrics(url, appName string, flushInterval time.Duration, logg
 		gauges:   make(map[string]prometheus.Gauge),
 		counters: make(map[string]prometheus.Counter),
 		logger:   logger,
+		stop:     stop,
 	}
 }
 
+// Handler returns an HTTP handler that exposes the collected metrics in the Prometheus format,
+// so they can be scraped in addition to being pushed.
+func (m *PrometheusMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
 // Increment is used to count occurances. It can only be used for values that never decrease.
+// It is safe for concurrent use.
 func (m *PrometheusMetrics) Increment(name string) {
+	m.counter(name).Inc()
+}
+
+// SetGauge is used to track a float64 value over time.
+// It is safe for concurrent use.
+func (m *PrometheusMetrics) SetGauge(name string, value float64) {
+	m.gauge(name).Set(value)
+}
+
+// SetGaugeInt64 is used to track an int64 value over time.
+// The integer value will be converted to a float to fit the prometheus API.
+// It is safe for concurrent use.
+func (m *PrometheusMetrics) SetGaugeInt64(name string, value int64) {
+	m.gauge(name).Set(float64(value))
+}
+
+// DurationSince is a utility method that accepts a metrics name and start time.
+// It then calculates the duration between the start time and now.
+// The result is converted to milliseconds and then tracked using SetGauge.
+func (m *PrometheusMetrics) DurationSince(name string, start time.Time) {
+	durationInMs := float64(time.Since(start).Round(time.Millisecond) / time.Millisecond)
+	m.SetGauge(name, durationInMs)
+}
+
+// counter returns the counter with the given name and creates and registers it on first use.
+// The registration happens outside of the lock because a failure is logged and the logger
+// may count dropped entries with this Measurer.
+func (m *PrometheusMetrics) counter(name string) prometheus.Counter {
+	m.mu.Lock()
 	counter, ok := m.counters[name]
 	if !ok {
 		counter = prometheus.NewCounter(prometheus.CounterOpts{
 			Name: name,
 		})
 		m.counters[name] = counter
+	}
+	m.mu.Unlock()
+
+	if !ok {
 		m.register(name, counter)
 	}
-	counter.Inc()
+	return counter
 }
 
-// SetGauge is used to track a float64 value over time.
-func (m *PrometheusMetrics) SetGauge(name string, value float64) {
+// gauge returns the gauge with the given name and creates and registers it on first use.
+func (m *PrometheusMetrics) gauge(name string) prometheus.Gauge {
+	m.mu.Lock()
 	gauge, ok := m.gauges[name]
 	if !ok {
 		gauge = prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: name,
 		})
 		m.gauges[name] = gauge
-		m.register(name, gauge)
 	}
-	gauge.Set(value)
-}
+	m.mu.Unlock()
 
-// SetGaugeInt64 is used to track an int64 value over time.
-// The integer value will be converted to a float to fit the prometheus API.
-func (m *PrometheusMetrics) SetGaugeInt64(name string, value int64) {
-	gauge, ok := m.gauges[name]
 	if !ok {
-		gauge = prometheus.NewGauge(prometheus.GaugeOpts{
-			Name: name,
-		})
-		m.gauges[name] = gauge
 		m.register(name, gauge)
 	}
-	gauge.Set(float64(value))
-}
-
-// DurationSince is a utility method that accepts a metrics name and start time.
-// It then calculates the duration between the start time and now.
-// The result is converted to milliseconds and then tracked using SetGauge.
-func (m *PrometheusMetrics) DurationSince(name string, start time.Time) {
-	durationInMs := float64(time.Since(start).Round(time.Millisecond) / time.Millisecond)
-	m.SetGauge(name, durationInMs)
+	return gauge
 }
 
 func (m *PrometheusMetrics) register(name string, collector prometheus.Collector) {
@@ -98,12 +130,28 @@ func (m *PrometheusMetrics) register(name string, collector prometheus.Collector
 	}
 }
 
+// Close stops the periodic pushing and pushes the metrics a last time so no values get lost during a shutdown.
+func (m *PrometheusMetrics) Close() error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	return m.pusher.Add()
+}
+
 // continuouslyPush calls the Add method of pusher periodically so the metrics get pushed to Prometheus.
-func continuouslyPush(pusher *push.Pusher, flushInterval time.Duration, logger Logger) {
-	for range time.Tick(flushInterval) {
-		err := pusher.Add()
-		if err != nil {
-			logger.WithError(err).Error("failed to push metrics")
+// It returns when the stop channel is closed.
+func continuouslyPush(pusher *push.Pusher, flushInterval time.Duration, logger Logger, stop chan struct{}) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := pusher.Add()
+			if err != nil {
+				logger.WithError(err).Error("failed to push metrics")
+			}
+		case <-stop:
+			return
 		}
 	}
 }
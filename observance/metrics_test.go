@@ -36,6 +36,17 @@ func TestNewPrometheusMetrics(t *testing.T) {
 	})
 }
 
+func TestMetricsHandler(t *testing.T) {
+	m := NewPrometheusMetrics("http://localhost:0", "test-app", time.Hour, NewTestLogger())
+	defer m.Close()
+	m.Increment("test_metric")
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "test_metric 1")
+}
+
 func TestMetricTypes(t *testing.T) {
 	var m Measurer
 	cases := []struct {
@@ -78,6 +89,25 @@ func TestMetricTypes(t *testing.T) {
 	}
 }
 
+func TestClose(t *testing.T) {
+	var callCounter uint64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddUint64(&callCounter, 1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	m := NewPrometheusMetrics(ts.URL, "test-app", 20*time.Millisecond, NewTestLogger())
+	m.Increment("test_metric")
+
+	assert.NoError(t, m.Close())
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&callCounter), "final push")
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, uint64(1), atomic.LoadUint64(&callCounter), "no more pushes after close")
+	assert.NoError(t, m.Close(), "closing twice is possible")
+}
+
 func TestMeasurer(t *testing.T) {
 	assert.Implements(t, (*Measurer)(nil), &PrometheusMetrics{})
 }
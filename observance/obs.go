@@ -1,10 +1,13 @@
 package observance
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"runtime/debug"
 	"time"
+
+	"github.com/getsentry/sentry-go"
 )
 
 // Config contains all config variables for setting up observability (logging, metrics).
@@ -21,6 +24,16 @@ type Config struct {
 	// E.g. map[string]string{"FastBill-RequestId": "requestId"} means that if the header "FastBill-RequestId" was found
 	// in the request headers the value will be added to the logger under the name "requestId".
 	LoggedHeaders map[string]string
+	// SentryTransport replaces the transport that is used to send events to Sentry (optional).
+	// If it is set, errors are sent to this transport even if no SentryURL was provided.
+	// Use NewTestSentry to capture the events in memory in tests.
+	SentryTransport sentry.Transport
+	// Redaction defines which sensitive values (e.g. passwords, IBANs, tokens) are masked in all log entries
+	// before they are written or sent to Sentry. Struct fields tagged with `log:"redact"` are always masked.
+	Redaction RedactionConfig
+	// Sampling limits how many identical log entries (same level and message) are written per interval (optional).
+	// If metrics are set up, the number of dropped entries is counted per level.
+	Sampling SamplingConfig
 }
 
 // Obs is a wrapper for all things that helps to observe the operation of
@@ -29,6 +42,8 @@ type Obs struct {
 	Logger        Logger
 	Metrics       Measurer
 	loggedHeaders map[string]string
+	appName       string
+	version       string
 }
 
 // NewObs creates a new observance instance for logging.
@@ -43,6 +58,8 @@ func NewObs(config Config) (*Obs, error) {
 	obs := &Obs{
 		Logger:        log,
 		loggedHeaders: config.LoggedHeaders,
+		appName:       config.AppName,
+		version:       config.Version,
 	}
 
 	if config.MetricsURL == "" {
@@ -51,12 +68,62 @@ func NewObs(config Config) (*Obs, error) {
 
 	metrics := NewPrometheusMetrics(config.MetricsURL, config.AppName, config.MetricsFlushInterval, log)
 	obs.Metrics = metrics
+	if logrusLogger, ok := log.(*LogrusLogger); ok {
+		logrusLogger.SetMeasurer(metrics)
+	}
 	return obs, nil
 }
 
+// AppName returns the name of the application that was set in the config.
+func (o *Obs) AppName() string {
+	return o.appName
+}
+
+// Version returns the version of the application that was set in the config, e.g. for the build info.
+func (o *Obs) Version() string {
+	return o.version
+}
+
+type logFieldsKey struct{}
+
+// ContextWithLogFields returns a copy of the context that carries the given log fields in addition to the ones
+// that were already added. CopyWithRequest adds them to the logger, e.g. the subject of an authenticated user.
+func ContextWithLogFields(ctx context.Context, fields Fields) context.Context {
+	merged := Fields{}
+	for key, value := range LogFieldsFromContext(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// LogFieldsFromContext returns the log fields added via ContextWithLogFields.
+// The returned map must not be modified.
+func LogFieldsFromContext(ctx context.Context) Fields {
+	fields, _ := ctx.Value(logFieldsKey{}).(Fields)
+	return fields
+}
+
+type obsKey struct{}
+
+// ContextWithObs returns a copy of the context that carries the given Obs, e.g. the request specific Obs
+// that server.Handle passes to the handlers.
+func ContextWithObs(ctx context.Context, obs *Obs) context.Context {
+	return context.WithValue(ctx, obsKey{}, obs)
+}
+
+// ObsFromContext returns the Obs added via ContextWithObs or nil if the context does not carry one.
+func ObsFromContext(ctx context.Context) *Obs {
+	obs, _ := ctx.Value(obsKey{}).(*Obs)
+	return obs
+}
+
 // CopyWithRequest creates a new observance and adds request-specific fields to
 // the logger (and maybe at some point to the other parts of observance, too).
 // The headers specified in the config (LoggedHeaders) will be added as log fields with their specified field names.
+// Fields added to the request context via ContextWithLogFields are added as well.
 func (o *Obs) CopyWithRequest(r *http.Request) *Obs {
 	obCopy := *o
 	obs := &obCopy
@@ -73,14 +140,45 @@ func (o *Obs) CopyWithRequest(r *http.Request) *Obs {
 		}
 	}
 
+	if fields := LogFieldsFromContext(r.Context()); len(fields) > 0 {
+		obs.Logger = obs.Logger.WithFields(fields)
+	}
+
 	return obs
 }
 
+// SetLevel changes the log level at runtime.
+// As all request specific copies share the logger of the original observance instance, they are affected as well.
+// An error is returned if the level is invalid or the logger does not support changing the level.
+func (o *Obs) SetLevel(level string) error {
+	levelSetter, ok := o.Logger.(LevelSetter)
+	if !ok {
+		return fmt.Errorf("logger of type %T does not support changing the log level", o.Logger)
+	}
+	return levelSetter.SetLevel(level)
+}
+
+// PanicKey is the name of the log field that marks entries about recovered panics.
+const PanicKey = "panic"
+
 // PanicRecover can be used to recover panics in the main thread and log the messages.
 func (o *Obs) PanicRecover() {
 	if r := recover(); r != nil {
 		// According to Russ Cox (leader of the Go team) capturing the stack trace here works:
 		// https://groups.google.com/d/msg/golang-nuts/MB8GyW5j2UY/m_YYy7mGYbIJ .
-		o.Logger.WithField("stack", string(debug.Stack())).Error(fmt.Sprintf("%v", r))
+		o.LogPanic(r, debug.Stack())
+	}
+}
+
+// LogPanic logs a recovered panic with the stack trace in the field "stack" and marks it with the field PanicKey.
+// If the recovered value is an error, it is attached to the log entry. The entry is sent to Sentry as fatal event.
+func (o *Obs) LogPanic(recovered interface{}, stack []byte) {
+	logger := o.Logger.WithFields(Fields{
+		PanicKey: true,
+		"stack":  string(stack),
+	})
+	if err, ok := recovered.(error); ok {
+		logger = logger.WithError(err)
 	}
+	logger.Error(fmt.Sprintf("%v", recovered))
 }
@@ -2,6 +2,8 @@ package observance
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http/httptest"
 	"testing"
 
@@ -12,6 +14,7 @@ func TestCopyWithRequest(t *testing.T) {
 	config := Config{
 		AppName:  "testApp",
 		LogLevel: "debug",
+		Version:  "1.2.3",
 		LoggedHeaders: map[string]string{
 			"Fastbill-Outer-RequestId": "requestId",
 			"Fastbill-AccountId":       "accountId",
@@ -22,6 +25,12 @@ func TestCopyWithRequest(t *testing.T) {
 	capture := bytes.Buffer{}
 	obs.Logger.SetOutput(&capture)
 
+	t.Run("keeps app name and version", func(t *testing.T) {
+		obsCopy := obs.CopyWithRequest(httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, "testApp", obsCopy.AppName())
+		assert.Equal(t, "1.2.3", obsCopy.Version())
+	})
+
 	t.Run("adds request properties to all log messages", func(t *testing.T) {
 		r := httptest.NewRequest("POST", "http://example.com/test?foo=bar", nil)
 		r.Header.Set("Fastbill-Outer-RequestId", "testRequestId")
@@ -59,6 +68,20 @@ func TestCopyWithRequest(t *testing.T) {
 		assert.NotContains(t, got, "accountId")
 	})
 
+	t.Run("adds fields from the request context", func(t *testing.T) {
+		capture = bytes.Buffer{}
+		r := httptest.NewRequest("GET", "http://example.com", nil)
+		ctx := ContextWithLogFields(r.Context(), Fields{"subject": "user1", "tenant": "a"})
+		ctx = ContextWithLogFields(ctx, Fields{"tenant": "b"})
+
+		reqObs := obs.CopyWithRequest(r.WithContext(ctx))
+		reqObs.Logger.Error("some message")
+		got := capture.String()
+		assert.Contains(t, got, `"subject":"user1"`)
+		assert.Contains(t, got, `"tenant":"b"`)
+		assert.Equal(t, Fields{"subject": "user1", "tenant": "a"}, LogFieldsFromContext(ContextWithLogFields(r.Context(), Fields{"subject": "user1", "tenant": "a"})))
+	})
+
 	t.Run("main observance is not affected", func(t *testing.T) {
 		capture = bytes.Buffer{}
 		obs.Logger.Error("some message")
@@ -68,3 +91,62 @@ func TestCopyWithRequest(t *testing.T) {
 	})
 
 }
+
+func TestObsFromContext(t *testing.T) {
+	assert.Nil(t, ObsFromContext(context.Background()))
+
+	obs := &Obs{Logger: NewTestLogger()}
+	assert.Same(t, obs, ObsFromContext(ContextWithObs(context.Background(), obs)))
+}
+
+func TestSetLevel(t *testing.T) {
+	obs, err := NewObs(Config{LogLevel: "info"})
+	assert.NoError(t, err)
+	capture := bytes.Buffer{}
+	obs.Logger.SetOutput(&capture)
+	reqObs := obs.CopyWithRequest(httptest.NewRequest("GET", "http://example.com", nil))
+
+	t.Run("success", func(t *testing.T) {
+		err := obs.SetLevel("debug")
+		assert.NoError(t, err)
+		assert.Equal(t, "debug", obs.Logger.Level())
+
+		reqObs.Logger.Debug("testMessage")
+		assert.Contains(t, capture.String(), `"msg":"testMessage"`)
+	})
+
+	t.Run("invalid level", func(t *testing.T) {
+		err := obs.SetLevel("verbose")
+		assert.Error(t, err)
+		assert.Equal(t, "debug", obs.Logger.Level())
+	})
+
+	t.Run("logger without support", func(t *testing.T) {
+		obs := &Obs{Logger: struct{ Logger }{NewTestLogger()}}
+		err := obs.SetLevel("debug")
+		assert.Error(t, err)
+	})
+}
+
+func TestLogPanic(t *testing.T) {
+	testLogger := NewTestLogger()
+	obs := &Obs{Logger: testLogger}
+
+	t.Run("value", func(t *testing.T) {
+		obs.LogPanic("testPanic", []byte("testStack"))
+
+		assert.Equal(t, TestLogEntry{
+			Level:   "error",
+			Message: "testPanic",
+			Data:    map[string]interface{}{PanicKey: true, "stack": "testStack"},
+		}, testLogger.LastEntry())
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := errors.New("testError")
+		obs.LogPanic(err, []byte("testStack"))
+
+		assert.Equal(t, "testError", testLogger.LastEntry().Message)
+		assert.Equal(t, err, testLogger.LastEntry().Data["error"])
+	})
+}
@@ -0,0 +1,378 @@
+package observance
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces all values that were identified as sensitive by the redaction.
+const RedactedValue = "[REDACTED]"
+
+// CyclicValue replaces values that reference themselves, they cannot be logged completely.
+const CyclicValue = "[CYCLIC]"
+
+// RedactionConfig defines which values are masked in the log entries before they are formatted or sent to Sentry.
+// Redaction is applied to the log message and the log fields including nested maps, slices and structs.
+type RedactionConfig struct {
+	// Fields contains the names of fields (e.g. "password" or "iban") whose values are replaced completely.
+	// The comparison is case-insensitive and also applies to keys of nested maps and to struct fields
+	// (by their JSON name or Go name).
+	Fields []string
+	// Patterns contains regular expressions. All matches in the message and in string values are replaced.
+	Patterns []string
+}
+
+// Struct fields with the tag `log:"redact"` are always redacted, independent of the config.
+const (
+	redactTagName  = "log"
+	redactTagValue = "redact"
+)
+
+// typeFlags describe what a type can contain that might need to be redacted.
+type typeFlags uint8
+
+const (
+	// flagFields is set for structs with fields that are redacted by their tag or name.
+	flagFields typeFlags = 1 << iota
+	// flagDynamic is set for interfaces and maps with string keys whose content is only known at runtime.
+	flagDynamic
+	// flagStrings is set for strings that the patterns are applied to.
+	flagStrings
+)
+
+type redactor struct {
+	fields   map[string]bool
+	patterns []*regexp.Regexp
+	// types caches the typeFlags of the logged types.
+	types sync.Map
+}
+
+func newRedactor(config RedactionConfig) (*redactor, error) {
+	r := &redactor{
+		fields: make(map[string]bool, len(config.Fields)),
+	}
+
+	for _, field := range config.Fields {
+		r.fields[strings.ToLower(field)] = true
+	}
+
+	for _, pattern := range config.Patterns {
+		compiledPattern, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, compiledPattern)
+	}
+
+	return r, nil
+}
+
+// visit identifies a pointer, map or slice that is currently being redacted, so cycles are detected.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// redactFields returns a copy of the given fields with all sensitive values replaced.
+// The input is not modified.
+func (r *redactor) redactFields(fields Fields) Fields {
+	result := make(Fields, len(fields))
+	visited := map[visit]bool{}
+	for key, value := range fields {
+		result[key] = r.redactField(key, value, visited)
+	}
+	return result
+}
+
+func (r *redactor) redactField(name string, value interface{}, visited map[visit]bool) interface{} {
+	if r.fields[strings.ToLower(name)] {
+		return RedactedValue
+	}
+	return r.redactValue(value, visited)
+}
+
+// redactValue walks through the value and returns a redacted copy.
+// Structs are converted to maps (keyed by their JSON names) so single fields can be replaced. Values whose type
+// cannot contain anything sensitive are returned unchanged. Errors stay errors, only their text is redacted.
+func (r *redactor) redactValue(value interface{}, visited map[visit]bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	if err, ok := value.(error); ok {
+		return r.redactError(err)
+	}
+
+	flags := r.typeFlags(reflect.TypeOf(value))
+	if !r.needsRedaction(flags) {
+		return value
+	}
+	if flags&flagFields == 0 {
+		// Types that define their own representation are only converted into maps if they contain fields that
+		// need to be redacted. Otherwise only the patterns are applied to their representation.
+		if text, ok := textRepresentation(value); ok {
+			if redacted := r.redactString(text); redacted != text {
+				return redacted
+			}
+			return value
+		}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return value
+		}
+		return r.redactReference(rv, visited, func() interface{} {
+			return r.redactValue(rv.Elem().Interface(), visited)
+		})
+	case reflect.Map:
+		return r.redactReference(rv, visited, func() interface{} {
+			return r.redactMap(rv, visited)
+		})
+	case reflect.Slice, reflect.Array:
+		return r.redactSlice(rv, visited)
+	case reflect.Struct:
+		return r.redactStruct(rv, visited)
+	case reflect.String:
+		return r.redactString(rv.String())
+	default:
+		return value
+	}
+}
+
+func (r *redactor) needsRedaction(flags typeFlags) bool {
+	return flags&(flagFields|flagDynamic) != 0 || flags&flagStrings != 0 && len(r.patterns) > 0
+}
+
+// redactReference redacts the value a pointer or map refers to. Values that refer to themselves are replaced by
+// CyclicValue instead of recursing endlessly.
+func (r *redactor) redactReference(rv reflect.Value, visited map[visit]bool, redact func() interface{}) interface{} {
+	key := visit{ptr: rv.Pointer(), typ: rv.Type()}
+	if visited[key] {
+		return CyclicValue
+	}
+	visited[key] = true
+	defer delete(visited, key)
+	return redact()
+}
+
+// textRepresentation returns the text of values that define their own representation.
+func textRepresentation(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case json.Marshaler:
+		data, err := v.MarshalJSON()
+		return string(data), err == nil
+	case encoding.TextMarshaler:
+		data, err := v.MarshalText()
+		return string(data), err == nil
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+func (r *redactor) redactMap(rv reflect.Value, visited map[visit]bool) interface{} {
+	if rv.Type().Key().Kind() != reflect.String {
+		return rv.Interface()
+	}
+	result := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key := iter.Key().String()
+		result[key] = r.redactField(key, iter.Value().Interface(), visited)
+	}
+	return result
+}
+
+func (r *redactor) redactSlice(rv reflect.Value, visited map[visit]bool) interface{} {
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return rv.Interface()
+	}
+	redact := func() interface{} {
+		result := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			result[i] = r.redactValue(rv.Index(i).Interface(), visited)
+		}
+		return result
+	}
+	if rv.Kind() == reflect.Slice && rv.Len() > 0 {
+		return r.redactReference(rv, visited, redact)
+	}
+	return redact()
+}
+
+func (r *redactor) redactStruct(rv reflect.Value, visited map[visit]bool) map[string]interface{} {
+	rt := rv.Type()
+	result := make(map[string]interface{}, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, ok := logFieldName(field)
+		if !ok {
+			continue
+		}
+
+		if r.isRedactedField(field) {
+			result[name] = RedactedValue
+			continue
+		}
+		result[name] = r.redactField(name, rv.Field(i).Interface(), visited)
+	}
+	return result
+}
+
+// logFieldName returns the JSON name of the struct field. Unexported fields and fields with the JSON name "-"
+// would not be logged by the JSON formatter either.
+func logFieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	jsonName := strings.Split(field.Tag.Get("json"), ",")[0]
+	switch jsonName {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return jsonName, true
+	}
+}
+
+func (r *redactor) isRedactedField(field reflect.StructField) bool {
+	if field.Tag.Get(redactTagName) == redactTagValue || r.fields[strings.ToLower(field.Name)] {
+		return true
+	}
+	name, _ := logFieldName(field)
+	return r.fields[strings.ToLower(name)]
+}
+
+// typeFlags returns the flags of all types that can be reached from the given type. They are cached per type,
+// so the types are only inspected once.
+func (r *redactor) typeFlags(t reflect.Type) typeFlags {
+	if flags, ok := r.types.Load(t); ok {
+		return flags.(typeFlags)
+	}
+	flags := r.collectTypeFlags(t, map[reflect.Type]bool{})
+	r.types.Store(t, flags)
+	return flags
+}
+
+func (r *redactor) collectTypeFlags(t reflect.Type, seen map[reflect.Type]bool) typeFlags {
+	if seen[t] {
+		return 0
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return flagDynamic
+	case reflect.String:
+		return flagStrings
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return r.collectTypeFlags(t.Elem(), seen)
+	case reflect.Map:
+		flags := r.collectTypeFlags(t.Elem(), seen)
+		if t.Key().Kind() == reflect.String && len(r.fields) > 0 {
+			flags |= flagDynamic
+		}
+		return flags
+	case reflect.Struct:
+		var flags typeFlags
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if _, ok := logFieldName(field); !ok {
+				continue
+			}
+			if r.isRedactedField(field) {
+				flags |= flagFields
+			}
+			flags |= r.collectTypeFlags(field.Type, seen)
+		}
+		return flags
+	default:
+		return 0
+	}
+}
+
+func (r *redactor) redactString(value string) string {
+	for _, pattern := range r.patterns {
+		value = pattern.ReplaceAllString(value, RedactedValue)
+	}
+	return value
+}
+
+// redactError returns an error whose text has the patterns applied. The error is returned unchanged if neither
+// its text nor the text of the errors it wraps contains anything that needs to be redacted.
+func (r *redactor) redactError(err error) error {
+	if len(r.patterns) == 0 {
+		return err
+	}
+	for wrapped := err; wrapped != nil; wrapped = errors.Unwrap(wrapped) {
+		if text := wrapped.Error(); r.redactString(text) != text {
+			return &redactedError{err: err, redactor: r}
+		}
+	}
+	return err
+}
+
+// redactedError hides the sensitive parts of the text of an error. The errors it wraps are redacted as well,
+// so the chain can still be inspected with errors.Is and errors.As without revealing the original texts.
+type redactedError struct {
+	err      error
+	redactor *redactor
+}
+
+func (e *redactedError) Error() string {
+	return e.redactor.redactString(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	wrapped := errors.Unwrap(e.err)
+	if wrapped == nil {
+		return nil
+	}
+	return e.redactor.redactError(wrapped)
+}
+
+func (e *redactedError) Is(target error) bool {
+	return errors.Is(e.err, target)
+}
+
+func (e *redactedError) As(target interface{}) bool {
+	return errors.As(e.err, target)
+}
+
+// unredactedError returns the original error of a redacted one. It must only be used for information that
+// does not contain the error text, like the stack trace.
+func unredactedError(err error) error {
+	if redacted, ok := err.(*redactedError); ok {
+		return redacted.err
+	}
+	return err
+}
+
+// redactionHook applies the redaction to the log message and fields.
+// It needs to be the first hook that is registered so no other hook receives the sensitive data.
+type redactionHook struct {
+	redactor *redactor
+}
+
+func (hook *redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire replaces the message and the fields of the entry with the redacted version.
+// Logrus passes a copy of the entry to the hooks so the fields of the logger are not affected.
+func (hook *redactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = hook.redactor.redactString(entry.Message)
+	entry.Data = hook.redactor.redactFields(Fields(entry.Data))
+	return nil
+}
@@ -0,0 +1,282 @@
+package observance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAccount struct {
+	Name     string `json:"name"`
+	IBAN     string `json:"iban"`
+	Token    string `json:"token" log:"redact"`
+	Ignored  string `json:"-"`
+	internal string
+	Address  *testAddress `json:"address"`
+}
+
+type testAddress struct {
+	Street string
+	Note   string `json:"note"`
+}
+
+// testCard has its own text representation but contains a field that needs to be redacted.
+type testCard struct {
+	Holder string `json:"holder"`
+	Number string `json:"number" log:"redact"`
+}
+
+func (c testCard) String() string {
+	return c.Holder + " " + c.Number
+}
+
+type testReference string
+
+func (r testReference) MarshalText() ([]byte, error) {
+	return []byte("ref:" + string(r)), nil
+}
+
+type testNode struct {
+	Name string    `json:"name"`
+	Next *testNode `json:"next"`
+}
+
+func TestRedactor(t *testing.T) {
+	r, err := newRedactor(RedactionConfig{
+		Fields:   []string{"Password", "iban"},
+		Patterns: []string{`DE\d{20}`},
+	})
+	require.NoError(t, err)
+
+	t.Run("flat fields", func(t *testing.T) {
+		input := Fields{
+			"password": "secret",
+			"IBAN":     "DE89370400440532013000",
+			"message":  "transfer to DE89370400440532013000 done",
+			"count":    3,
+		}
+		result := r.redactFields(input)
+
+		assert.Equal(t, Fields{
+			"password": RedactedValue,
+			"IBAN":     RedactedValue,
+			"message":  "transfer to [REDACTED] done",
+			"count":    3,
+		}, result)
+		assert.Equal(t, "secret", input["password"], "input must not be modified")
+	})
+
+	t.Run("nested maps and slices", func(t *testing.T) {
+		input := Fields{
+			"body": map[string]interface{}{
+				"user": map[string]string{
+					"name":     "John",
+					"password": "secret",
+				},
+				"accounts": []interface{}{
+					map[string]interface{}{"iban": "DE89370400440532013000"},
+					"DE89370400440532013000",
+				},
+			},
+		}
+		result := r.redactFields(input)
+
+		assert.Equal(t, map[string]interface{}{
+			"user": map[string]interface{}{
+				"name":     "John",
+				"password": RedactedValue,
+			},
+			"accounts": []interface{}{
+				map[string]interface{}{"iban": RedactedValue},
+				RedactedValue,
+			},
+		}, result["body"])
+		assert.Equal(t, "secret", input["body"].(map[string]interface{})["user"].(map[string]string)["password"])
+	})
+
+	t.Run("structs", func(t *testing.T) {
+		input := Fields{
+			"account": &testAccount{
+				Name:     "John",
+				IBAN:     "DE89370400440532013000",
+				Token:    "abc",
+				Ignored:  "ignored",
+				internal: "internal",
+				Address:  &testAddress{Street: "Main Street", Note: "IBAN DE89370400440532013000"},
+			},
+		}
+		result := r.redactFields(input)
+
+		assert.Equal(t, map[string]interface{}{
+			"name":  "John",
+			"iban":  RedactedValue,
+			"token": RedactedValue,
+			"address": map[string]interface{}{
+				"Street": "Main Street",
+				"note":   "IBAN [REDACTED]",
+			},
+		}, result["account"])
+	})
+
+	t.Run("values with own representation are kept", func(t *testing.T) {
+		testErr := errors.New("testError")
+		now := time.Now()
+		input := Fields{
+			"error": testErr,
+			"time":  now,
+			"bytes": []byte("DE89370400440532013000"),
+			"nil":   nil,
+		}
+		result := r.redactFields(input)
+
+		assert.Equal(t, testErr, result["error"])
+		assert.Equal(t, now, result["time"])
+		assert.Equal(t, []byte("DE89370400440532013000"), result["bytes"])
+		assert.Nil(t, result["nil"])
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		testErr := errors.New("transfer to DE89370400440532013000 failed")
+		wrappedErr := fmt.Errorf("booking: %w", testErr)
+
+		result := r.redactFields(Fields{"error": wrappedErr})
+
+		redactedErr, ok := result["error"].(error)
+		require.True(t, ok)
+		assert.Equal(t, "booking: transfer to [REDACTED] failed", redactedErr.Error())
+		assert.True(t, errors.Is(redactedErr, testErr))
+		unwrapped := errors.Unwrap(redactedErr)
+		require.NotNil(t, unwrapped)
+		assert.Equal(t, "transfer to [REDACTED] failed", unwrapped.Error())
+		assert.Equal(t, "booking: transfer to DE89370400440532013000 failed", wrappedErr.Error(), "input must not be modified")
+	})
+
+	t.Run("values with own representation", func(t *testing.T) {
+		result := r.redactFields(Fields{
+			"card":      testCard{Holder: "John", Number: "4111"},
+			"reference": testReference("DE89370400440532013000"),
+			"other":     testReference("123"),
+		})
+
+		assert.Equal(t, map[string]interface{}{"holder": "John", "number": RedactedValue}, result["card"],
+			"tagged fields are redacted although the type is a fmt.Stringer")
+		assert.Equal(t, "ref:[REDACTED]", result["reference"])
+		assert.Equal(t, testReference("123"), result["other"])
+	})
+
+	t.Run("cycles", func(t *testing.T) {
+		node := &testNode{Name: "DE89370400440532013000"}
+		node.Next = node
+		m := map[string]interface{}{"name": "John"}
+		m["self"] = m
+
+		result := r.redactFields(Fields{"node": node, "map": m})
+
+		assert.Equal(t, map[string]interface{}{"name": RedactedValue, "next": CyclicValue}, result["node"])
+		assert.Equal(t, map[string]interface{}{"name": "John", "self": CyclicValue}, result["map"])
+	})
+
+	t.Run("shared values are no cycles", func(t *testing.T) {
+		address := &testAddress{Street: "Main Street"}
+		result := r.redactFields(Fields{"list": []*testAddress{address, address}})
+
+		expected := map[string]interface{}{"Street": "Main Street", "note": ""}
+		assert.Equal(t, []interface{}{expected, expected}, result["list"])
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		_, err := newRedactor(RedactionConfig{Patterns: []string{"("}})
+		assert.Error(t, err)
+	})
+}
+
+func TestRedactorWithoutConfig(t *testing.T) {
+	r, err := newRedactor(RedactionConfig{})
+	require.NoError(t, err)
+
+	address := &testAddress{Street: "Main Street"}
+	result := r.redactFields(Fields{"address": address, "account": testAccount{Token: "abc"}})
+
+	assert.Same(t, address, result["address"], "structs without tagged fields are not converted")
+	assert.Equal(t, RedactedValue, result["account"].(map[string]interface{})["token"])
+}
+
+func TestRedactionInLogger(t *testing.T) {
+	testSentry := NewTestSentry()
+	logger, err := NewLogrus(Config{
+		LogLevel:        "debug",
+		SentryTransport: testSentry,
+		Redaction: RedactionConfig{
+			Fields:   []string{"password"},
+			Patterns: []string{`DE\d{20}`},
+		},
+	})
+	require.NoError(t, err)
+	capture := bytes.Buffer{}
+	logger.SetOutput(&capture)
+
+	t.Run("log output", func(t *testing.T) {
+		capture.Reset()
+		logger.WithField("password", "secret").Info("testMessage")
+
+		got := capture.String()
+		assert.Contains(t, got, `"password":"[REDACTED]"`)
+		assert.NotContains(t, got, "secret")
+	})
+
+	t.Run("message", func(t *testing.T) {
+		capture.Reset()
+		logger.Info("transfer to DE89370400440532013000")
+
+		got := capture.String()
+		assert.Contains(t, got, "transfer to [REDACTED]")
+		assert.NotContains(t, got, "DE89370400440532013000")
+	})
+
+	t.Run("struct tag without config", func(t *testing.T) {
+		capture.Reset()
+		logger.WithField("account", testAccount{Token: "abc"}).Info("testMessage")
+
+		got := capture.String()
+		assert.Contains(t, got, `"token":"[REDACTED]"`)
+		assert.NotContains(t, got, "abc")
+	})
+
+	t.Run("Sentry", func(t *testing.T) {
+		testSentry.Reset()
+		logger.WithField("password", "secret").WithError(errors.New("testError")).Error("testMessage")
+
+		event := testSentry.LastEvent()
+		require.NotNil(t, event)
+		assert.Equal(t, RedactedValue, event.Extra["password"])
+		require.Len(t, event.Exception, 1)
+		assert.Equal(t, "testError", event.Exception[0].Value)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		capture.Reset()
+		logger.WithError(errors.New("transfer to DE89370400440532013000 failed")).Info("testMessage")
+
+		got := capture.String()
+		assert.Contains(t, got, `"error":"transfer to [REDACTED] failed"`)
+		assert.NotContains(t, got, "DE89370400440532013000")
+	})
+
+	t.Run("error in Sentry", func(t *testing.T) {
+		testSentry.Reset()
+		logger.WithError(fmt.Errorf("booking: %w", errors.New("transfer to DE89370400440532013000 failed"))).Error("testMessage")
+
+		event := testSentry.LastEvent()
+		require.NotNil(t, event)
+		require.Len(t, event.Exception, 2)
+		assert.Equal(t, "*errors.errorString", event.Exception[0].Type)
+		assert.Equal(t, "transfer to [REDACTED] failed", event.Exception[0].Value)
+		assert.Equal(t, "booking: transfer to [REDACTED] failed", event.Exception[1].Value)
+		assert.Equal(t, "booking: transfer to [REDACTED] failed", event.Extra["error"].(error).Error())
+	})
+}
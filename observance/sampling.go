@@ -0,0 +1,97 @@
+package observance
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// droppedEntriesMetricPrefix is the prefix of the metrics that count the log entries dropped by the sampling.
+// The level is appended, e.g. "log_entries_dropped_error".
+const droppedEntriesMetricPrefix = "log_entries_dropped_"
+
+// SamplingConfig limits the amount of identical log entries that are written in a certain interval.
+// Log entries are considered identical if they have the same level and message, the fields are not taken into account.
+// Within each interval the first Initial entries are logged. After that only every Thereafter-th entry is logged.
+// If Thereafter is 0 all further entries are dropped until the interval is over.
+// Sampling is disabled if Interval or Initial is not set.
+type SamplingConfig struct {
+	Interval   time.Duration
+	Initial    int
+	Thereafter int
+}
+
+func (c SamplingConfig) enabled() bool {
+	return c.Interval > 0 && c.Initial > 0
+}
+
+type samplingKey struct {
+	level   logrus.Level
+	message string
+}
+
+// sampler decides which log entries are written according to the sampling config.
+// The counters are reset for all messages at the beginning of each interval so the memory usage
+// is limited to the number of different messages logged within one interval.
+type sampler struct {
+	config SamplingConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	counts      map[samplingKey]int
+	windowStart time.Time
+	measurer    Measurer
+}
+
+func newSampler(config SamplingConfig) *sampler {
+	return &sampler{
+		config: config,
+		now:    time.Now,
+		counts: make(map[samplingKey]int),
+	}
+}
+
+// allow reports whether a log entry with the given level and message should be written.
+// Dropped entries are counted via the Measurer if one was set.
+// A nil sampler allows all entries.
+func (s *sampler) allow(level logrus.Level, msg interface{}) bool {
+	if s == nil {
+		return true
+	}
+
+	key := samplingKey{level: level, message: fmt.Sprint(msg)}
+
+	s.mu.Lock()
+	now := s.now()
+	if now.Sub(s.windowStart) >= s.config.Interval {
+		s.counts = make(map[samplingKey]int)
+		s.windowStart = now
+	}
+	s.counts[key]++
+	count := s.counts[key]
+	measurer := s.measurer
+	s.mu.Unlock()
+
+	if count <= s.config.Initial {
+		return true
+	}
+	if s.config.Thereafter > 0 && (count-s.config.Initial)%s.config.Thereafter == 0 {
+		return true
+	}
+
+	if measurer != nil {
+		measurer.Increment(droppedEntriesMetricPrefix + level.String())
+	}
+	return false
+}
+
+func (s *sampler) setMeasurer(measurer Measurer) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.measurer = measurer
+}
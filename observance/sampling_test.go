@@ -0,0 +1,143 @@
+package observance
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingMeasurer struct {
+	Measurer
+	counts map[string]int
+}
+
+func (m *countingMeasurer) Increment(name string) {
+	m.counts[name]++
+}
+
+func TestSampling(t *testing.T) {
+	setup := func(config SamplingConfig) (*LogrusLogger, *bytes.Buffer, *countingMeasurer, *time.Time) {
+		logger, err := NewLogrus(Config{LogLevel: "info", Sampling: config})
+		require.NoError(t, err)
+		logrusLogger := logger.(*LogrusLogger)
+
+		capture := &bytes.Buffer{}
+		logrusLogger.SetOutput(capture)
+
+		measurer := &countingMeasurer{counts: map[string]int{}}
+		logrusLogger.SetMeasurer(measurer)
+
+		now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		logrusLogger.sampler.now = func() time.Time { return now }
+		return logrusLogger, capture, measurer, &now
+	}
+
+	countLines := func(capture *bytes.Buffer, msg string) int {
+		return strings.Count(capture.String(), `"msg":"`+msg+`"`)
+	}
+
+	t.Run("first N then every Mth", func(t *testing.T) {
+		logger, capture, measurer, _ := setup(SamplingConfig{Interval: time.Second, Initial: 3, Thereafter: 5})
+
+		for i := 0; i < 20; i++ {
+			logger.Error("testMessage")
+		}
+
+		// Entries 1, 2, 3, 8, 13 and 18 are written.
+		assert.Equal(t, 6, countLines(capture, "testMessage"))
+		assert.Equal(t, 14, measurer.counts["log_entries_dropped_error"])
+	})
+
+	t.Run("drop all after N", func(t *testing.T) {
+		logger, capture, measurer, _ := setup(SamplingConfig{Interval: time.Second, Initial: 2})
+
+		for i := 0; i < 10; i++ {
+			logger.Warn("testMessage")
+		}
+
+		assert.Equal(t, 2, countLines(capture, "testMessage"))
+		assert.Equal(t, 8, measurer.counts["log_entries_dropped_warning"])
+	})
+
+	t.Run("counted per level and message, fields are ignored", func(t *testing.T) {
+		logger, capture, _, _ := setup(SamplingConfig{Interval: time.Second, Initial: 1})
+
+		logger.WithField("id", 1).Error("testMessage")
+		logger.WithField("id", 2).Error("testMessage")
+		logger.Info("testMessage")
+		logger.Error("otherMessage")
+
+		assert.Equal(t, 2, countLines(capture, "testMessage"))
+		assert.Equal(t, 1, countLines(capture, "otherMessage"))
+		assert.NotContains(t, capture.String(), `"id":2`)
+	})
+
+	t.Run("counters are reset after the interval", func(t *testing.T) {
+		logger, capture, _, now := setup(SamplingConfig{Interval: time.Second, Initial: 1})
+
+		logger.Error("testMessage")
+		logger.Error("testMessage")
+		*now = now.Add(time.Second)
+		logger.Error("testMessage")
+
+		assert.Equal(t, 2, countLines(capture, "testMessage"))
+	})
+
+	t.Run("disabled levels are not counted", func(t *testing.T) {
+		logger, _, measurer, _ := setup(SamplingConfig{Interval: time.Second, Initial: 1})
+
+		logger.Debug("testMessage")
+		logger.Debug("testMessage")
+
+		assert.Empty(t, measurer.counts)
+	})
+
+	t.Run("no sampling by default", func(t *testing.T) {
+		logger, err := NewLogrus(Config{LogLevel: "info"})
+		require.NoError(t, err)
+		capture := &bytes.Buffer{}
+		logger.SetOutput(capture)
+
+		for i := 0; i < 10; i++ {
+			logger.Error("testMessage")
+		}
+
+		assert.Equal(t, 10, countLines(capture, "testMessage"))
+	})
+}
+
+func TestSamplingConcurrent(t *testing.T) {
+	logger, err := NewLogrus(Config{LogLevel: "info", Sampling: SamplingConfig{Interval: time.Hour, Initial: 1}})
+	require.NoError(t, err)
+	logger.SetOutput(ioutil.Discard)
+
+	metrics := NewPrometheusMetrics("http://localhost:0", "test-app", time.Hour, NewTestLogger())
+	defer metrics.Close()
+	logger.(*LogrusLogger).SetMeasurer(metrics)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				logger.Error("testMessage")
+				logger.Warn("testMessage")
+			}
+		}()
+	}
+	wg.Wait()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "log_entries_dropped_error 999")
+	assert.Contains(t, rec.Body.String(), "log_entries_dropped_warning 999")
+}
@@ -2,6 +2,7 @@ package observance
 
 import (
 	"errors"
+	"reflect"
 	"time"
 
 	"github.com/getsentry/sentry-go"
@@ -24,6 +25,9 @@ var (
 	}
 )
 
+// maxErrorDepth limits the number of wrapped errors that are sent to Sentry, it matches the limit of the Sentry SDK.
+const maxErrorDepth = 10
+
 type sentryOptions sentry.ClientOptions
 
 type sentryHook struct {
@@ -41,8 +45,6 @@ func (hook *sentryHook) Levels() []logrus.Level {
 }
 
 func (hook *sentryHook) Fire(entry *logrus.Entry) error {
-	exceptions := []sentry.Exception{}
-
 	err, ok := entry.Data[logrus.ErrorKey].(error)
 	if !ok && entry.Message != "" {
 		// This allows to have a stack trace, even though there was only a message provided.
@@ -53,18 +55,21 @@ func (hook *sentryHook) Fire(entry *logrus.Entry) error {
 		return nil
 	}
 
-	stacktrace := sentry.ExtractStacktrace(err)
-	if stacktrace == nil {
-		stacktrace = sentry.NewStacktrace()
+	exceptions := errorChain(err)
+	// The logged error is the last exception, it gets the message as type and at least the stack trace of the logging call.
+	loggedErr := &exceptions[len(exceptions)-1]
+	loggedErr.Type = entry.Message
+	if loggedErr.Stacktrace == nil {
+		loggedErr.Stacktrace = sentry.NewStacktrace()
+	}
+
+	level := logrusLevelsToSentryLevels[entry.Level]
+	if isPanic, _ := entry.Data[PanicKey].(bool); isPanic {
+		level = sentry.LevelFatal
 	}
-	exceptions = append(exceptions, sentry.Exception{
-		Type:       entry.Message,
-		Value:      err.Error(),
-		Stacktrace: stacktrace,
-	})
 
 	event := sentry.Event{
-		Level:       logrusLevelsToSentryLevels[entry.Level],
+		Level:       level,
 		Message:     hook.prefix + entry.Message,
 		Extra:       map[string]interface{}(entry.Data),
 		Tags:        hook.tags,
@@ -79,6 +84,23 @@ func (hook *sentryHook) Fire(entry *logrus.Entry) error {
 	return nil
 }
 
+// errorChain returns an exception for the error and every error it wraps. Like in the Sentry SDK the innermost error
+// comes first.
+func errorChain(err error) []sentry.Exception {
+	exceptions := []sentry.Exception{}
+	for depth := 0; err != nil && depth < maxErrorDepth; depth++ {
+		// Type and stack trace do not contain the error text, so they can be taken from the unredacted error.
+		original := unredactedError(err)
+		exceptions = append([]sentry.Exception{{
+			Type:       reflect.TypeOf(original).String(),
+			Value:      err.Error(),
+			Stacktrace: sentry.ExtractStacktrace(original),
+		}}, exceptions...)
+		err = errors.Unwrap(err)
+	}
+	return exceptions
+}
+
 func (hook *sentryHook) SetPrefix(prefix string) {
 	hook.prefix = prefix
 }
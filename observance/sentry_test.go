@@ -0,0 +1,102 @@
+package observance
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentryHook(t *testing.T) {
+	testSentry := NewTestSentry()
+	logger, err := NewLogrus(Config{
+		AppName:         "testApp",
+		LogLevel:        "debug",
+		Version:         "1.2.3",
+		SentryTransport: testSentry,
+	})
+	require.NoError(t, err)
+	logger.SetOutput(ioutil.Discard)
+
+	t.Run("flush", func(t *testing.T) {
+		flusher, ok := logger.(Flusher)
+		require.True(t, ok)
+		flusher.Flush()
+	})
+
+	t.Run("error with message", func(t *testing.T) {
+		testSentry.Reset()
+		logger.WithField("testField", "testValue").Error("testMessage")
+
+		require.Len(t, testSentry.Events(), 1)
+		event := testSentry.LastEvent()
+		assert.Equal(t, sentry.LevelError, event.Level)
+		assert.Equal(t, "testMessage", event.Message)
+		assert.Equal(t, "1.2.3", event.Release)
+		assert.Equal(t, map[string]string{}, event.Tags)
+		assert.Equal(t, "testValue", event.Extra["testField"])
+		assert.Equal(t, "testApp", event.Extra["name"])
+		require.Len(t, event.Exception, 1)
+		assert.Equal(t, "testMessage", event.Exception[0].Value)
+		assert.NotNil(t, event.Exception[0].Stacktrace)
+	})
+
+	t.Run("error with attached error", func(t *testing.T) {
+		testSentry.Reset()
+		logger.WithError(errors.New("testError")).Error("testMessage")
+
+		event := testSentry.LastEvent()
+		require.NotNil(t, event)
+		assert.Equal(t, "testMessage", event.Message)
+		require.Len(t, event.Exception, 1)
+		assert.Equal(t, "testMessage", event.Exception[0].Type)
+		assert.Equal(t, "testError", event.Exception[0].Value)
+	})
+
+	t.Run("error chain", func(t *testing.T) {
+		testSentry.Reset()
+		innerErr := errors.New("innerError")
+		logger.WithError(fmt.Errorf("outerError: %w", innerErr)).Error("testMessage")
+
+		event := testSentry.LastEvent()
+		require.NotNil(t, event)
+		require.Len(t, event.Exception, 2)
+		assert.Equal(t, "*errors.errorString", event.Exception[0].Type)
+		assert.Equal(t, "innerError", event.Exception[0].Value)
+		assert.Equal(t, "testMessage", event.Exception[1].Type)
+		assert.Equal(t, "outerError: innerError", event.Exception[1].Value)
+		assert.NotNil(t, event.Exception[1].Stacktrace)
+	})
+
+	t.Run("lower levels are not sent", func(t *testing.T) {
+		testSentry.Reset()
+		logger.Debug("testMessage")
+		logger.Info("testMessage")
+		logger.Warn("testMessage")
+
+		assert.Empty(t, testSentry.Events())
+		assert.Nil(t, testSentry.LastEvent())
+	})
+
+	t.Run("panics are sent as fatal", func(t *testing.T) {
+		testSentry.Reset()
+		obs := &Obs{Logger: logger}
+		func() {
+			defer obs.PanicRecover()
+			panic(errors.New("testPanic"))
+		}()
+
+		event := testSentry.LastEvent()
+		require.NotNil(t, event)
+		assert.Equal(t, sentry.LevelFatal, event.Level)
+		assert.Equal(t, "testPanic", event.Message)
+		assert.Equal(t, true, event.Extra[PanicKey])
+		assert.Contains(t, event.Extra["stack"], "sentry_test.go")
+		require.Len(t, event.Exception, 1)
+		assert.Equal(t, "testPanic", event.Exception[0].Value)
+	})
+}
@@ -0,0 +1,64 @@
+package observance
+
+import (
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+)
+
+// TestSentry is a Sentry transport for testing.
+// Instead of sending the events over the network it records them so they can be checked in tests.
+// It can be passed to the logger setup via Config.SentryTransport.
+type TestSentry struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+// NewTestSentry creates a new TestSentry transport.
+func NewTestSentry() *TestSentry {
+	return &TestSentry{
+		events: []*sentry.Event{},
+	}
+}
+
+// Configure implements sentry.Transport#Configure. There is nothing to configure for the test transport.
+func (s *TestSentry) Configure(options sentry.ClientOptions) {}
+
+// SendEvent implements sentry.Transport#SendEvent. It records the event.
+func (s *TestSentry) SendEvent(event *sentry.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+// Flush implements sentry.Transport#Flush. Events are recorded synchronously so it returns immediately.
+func (s *TestSentry) Flush(timeout time.Duration) bool {
+	return true
+}
+
+// LastEvent returns the last recorded event or nil if no event was recorded.
+func (s *TestSentry) LastEvent() *sentry.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) == 0 {
+		return nil
+	}
+	return s.events[len(s.events)-1]
+}
+
+// Events returns all recorded events.
+func (s *TestSentry) Events() []*sentry.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]*sentry.Event, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// Reset clears the recorded events to start fresh.
+func (s *TestSentry) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = []*sentry.Event{}
+}
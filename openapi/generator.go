@@ -0,0 +1,234 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Route is the route that serves the generated document.
+const Route = "/openapi.json"
+
+// parameterTags are the struct tags of the server.Binder and where the parameters are located.
+var parameterTags = []struct {
+	tag string
+	in  string
+}{
+	{tag: "param", in: "path"},
+	{tag: "query", in: "query"},
+	{tag: "header", in: "header"},
+}
+
+var pathParamPattern = regexp.MustCompile(`:([^/]+)`)
+
+// Config configures the Generator.
+type Config struct {
+	Info    Info
+	Servers []Server
+	// ExcludePrefixes are path prefixes of routes that are not documented, e.g. "/admin".
+	ExcludePrefixes []string
+}
+
+// Endpoint describes the request and response types of a route.
+type Endpoint struct {
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	// Request is a value of the struct the request is bound to with server.Binder. Fields with the tags `param`,
+	// `query` and `header` become parameters, the other fields form the JSON body.
+	// The validate tags are mapped to the constraints of the schemas.
+	Request interface{}
+	// Response is a value of the type that is sent as JSON body. Without it, the response has no body.
+	Response interface{}
+	// Status is the status code of the response, the default is 200.
+	Status int
+}
+
+// Generator creates the OpenAPI document from the routes of an echo server and the endpoints described via Describe.
+type Generator struct {
+	config    Config
+	mu        sync.RWMutex
+	endpoints map[string]Endpoint
+}
+
+// New creates a Generator.
+func New(config Config) *Generator {
+	return &Generator{config: config, endpoints: map[string]Endpoint{}}
+}
+
+// Describe adds the request and response types of the route. It returns the route,
+// so it can wrap the registration: g.Describe(e.POST("/invoices", create), openapi.Endpoint{...}).
+func (g *Generator) Describe(route *echo.Route, endpoint Endpoint) *echo.Route {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.endpoints[route.Method+" "+route.Path] = endpoint
+	return route
+}
+
+// Register adds the route /openapi.json that serves the document of all routes of the server.
+// The document is generated when it is requested, so routes added after Register are included.
+func (g *Generator) Register(e *echo.Echo, middleware ...echo.MiddlewareFunc) {
+	e.GET(Route, func(c echo.Context) error {
+		data, err := g.Document(e).JSON()
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, data)
+	}, middleware...)
+}
+
+// Document generates the OpenAPI document for the routes of the server. Routes with wildcards,
+// the route of the document itself and routes with excluded prefixes are omitted.
+// Routes that were not described are documented with their path parameters and an empty 200 response.
+func (g *Generator) Document(e *echo.Echo) *Document {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	builder := newSchemaBuilder()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.config.Info,
+		Servers: g.config.Servers,
+		Paths:   map[string]*PathItem{},
+	}
+
+	routes := e.Routes()
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path+" "+routes[i].Method < routes[j].Path+" "+routes[j].Method
+	})
+	for _, route := range routes {
+		if !g.documented(route) {
+			continue
+		}
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = &PathItem{}
+		}
+		doc.Paths[path].setOperation(route.Method, g.operation(builder, route))
+	}
+
+	if len(builder.components) > 0 {
+		doc.Components = &Components{Schemas: builder.components}
+	}
+	return doc
+}
+
+func (g *Generator) documented(route *echo.Route) bool {
+	if route.Path == Route || strings.Contains(route.Path, "*") || (&PathItem{}).operationField(route.Method) == nil {
+		return false
+	}
+	for _, prefix := range g.config.ExcludePrefixes {
+		if strings.HasPrefix(route.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Generator) operation(builder *schemaBuilder, route *echo.Route) *Operation {
+	endpoint := g.endpoints[route.Method+" "+route.Path]
+	operation := &Operation{
+		OperationID: endpoint.OperationID,
+		Summary:     endpoint.Summary,
+		Description: endpoint.Description,
+		Tags:        endpoint.Tags,
+		Deprecated:  endpoint.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+
+	if endpoint.Request != nil {
+		requestType := derefType(reflect.TypeOf(endpoint.Request))
+		operation.Parameters = parameters(builder, requestType)
+		operation.RequestBody = requestBody(builder, requestType, route.Method)
+	}
+	operation.Parameters = addPathParameters(operation.Parameters, route.Path)
+
+	status := endpoint.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &Response{Description: http.StatusText(status)}
+	if endpoint.Response != nil {
+		response.Content = map[string]MediaType{
+			echo.MIMEApplicationJSON: {Schema: builder.schema(reflect.TypeOf(endpoint.Response))},
+		}
+	}
+	operation.Responses[strconv.Itoa(status)] = response
+	return operation
+}
+
+// parameters returns the path, query and header parameters of the request struct including nested structs.
+func parameters(builder *schemaBuilder, t reflect.Type) []Parameter {
+	params := []Parameter{}
+	if t.Kind() != reflect.Struct {
+		return params
+	}
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		fieldParams := fieldParameters(builder, field)
+		if len(fieldParams) == 0 && derefType(field.Type).Kind() == reflect.Struct && !isParameter(field) {
+			fieldParams = parameters(builder, field.Type)
+		}
+		params = append(params, fieldParams...)
+	}
+	return params
+}
+
+func fieldParameters(builder *schemaBuilder, field reflect.StructField) []Parameter {
+	params := []Parameter{}
+	for _, location := range parameterTags {
+		name := field.Tag.Get(location.tag)
+		if name == "" {
+			continue
+		}
+		schema := builder.schema(field.Type)
+		required := applyRules(schema, field.Tag.Get("validate"))
+		params = append(params, Parameter{
+			Name:     name,
+			In:       location.in,
+			Required: required || location.in == "path",
+			Schema:   schema,
+		})
+	}
+	return params
+}
+
+// addPathParameters adds the parameters of the path that are not part of the request struct as strings.
+func addPathParameters(params []Parameter, path string) []Parameter {
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		found := false
+		for _, param := range params {
+			found = found || param.In == "path" && param.Name == match[1]
+		}
+		if !found {
+			params = append(params, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+	return params
+}
+
+// requestBody returns the JSON body of the request struct or nil if it has no body fields
+// or the method has no body.
+func requestBody(builder *schemaBuilder, t reflect.Type, method string) *RequestBody {
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete ||
+		t.Kind() == reflect.Struct && len(bodyFields(t)) == 0 {
+		return nil
+	}
+	return &RequestBody{
+		Required: true,
+		Content: map[string]MediaType{
+			echo.MIMEApplicationJSON: {Schema: builder.schema(t)},
+		},
+	}
+}
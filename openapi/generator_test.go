@@ -0,0 +1,214 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+type testAddress struct {
+	Street string `json:"street" validate:"required"`
+	Zip    string `json:"zip" validate:"len=5"`
+}
+
+type testCreateInvoice struct {
+	CustomerID int            `param:"customerId"`
+	Tenant     string         `header:"X-Tenant" validate:"required"`
+	DryRun     bool           `query:"dryRun"`
+	Title      string         `json:"title" validate:"required,max=100"`
+	Total      server.Amount  `json:"total" validate:"gt=0"`
+	Currency   string         `json:"currency" validate:"oneof=EUR USD"`
+	Email      string         `json:"email,omitempty" validate:"omitempty,email"`
+	Address    testAddress    `json:"address" validate:"required"`
+	Items      []string       `json:"items" validate:"min=1,dive,required"`
+	DueDate    *time.Time     `json:"dueDate"`
+	Metadata   map[string]int `json:"metadata"`
+	internal   string
+}
+
+type testInvoice struct {
+	ID      int         `json:"id"`
+	Title   string      `json:"title"`
+	Address testAddress `json:"address"`
+}
+
+type testListInvoices struct {
+	Limit  int      `query:"limit" validate:"gte=1,lte=100"`
+	Status []string `query:"status"`
+}
+
+func newTestServer(t *testing.T) (*echo.Echo, *Generator) {
+	t.Helper()
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	handler := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+
+	g := New(Config{Info: Info{Title: "Invoices", Version: "1.0.0"}, ExcludePrefixes: []string{"/admin"}})
+	g.Describe(e.POST("/customers/:customerId/invoices", handler), Endpoint{
+		OperationID: "createInvoice",
+		Summary:     "Create an invoice",
+		Tags:        []string{"invoices"},
+		Request:     testCreateInvoice{},
+		Response:    testInvoice{},
+		Status:      http.StatusCreated,
+	})
+	g.Describe(e.GET("/invoices", handler), Endpoint{
+		Request:  &testListInvoices{},
+		Response: []testInvoice{},
+	})
+	e.DELETE("/invoices/:id", handler)
+	e.GET("/admin/loglevel", handler)
+	e.GET("/static/*", handler)
+	g.Register(e)
+	return e, g
+}
+
+func TestDocument(t *testing.T) {
+	e, g := newTestServer(t)
+	doc := g.Document(e)
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "Invoices", doc.Info.Title)
+	assert.Len(t, doc.Paths, 3, "excluded routes, wildcards and the document route are omitted")
+
+	t.Run("described route", func(t *testing.T) {
+		operation := doc.Paths["/customers/{customerId}/invoices"].Post
+		require.NotNil(t, operation)
+		assert.Equal(t, "createInvoice", operation.OperationID)
+		assert.Equal(t, []Parameter{
+			{Name: "customerId", In: "path", Required: true, Schema: &Schema{Type: "integer", Format: "int64"}},
+			{Name: "X-Tenant", In: "header", Required: true, Schema: &Schema{Type: "string"}},
+			{Name: "dryRun", In: "query", Schema: &Schema{Type: "boolean"}},
+		}, operation.Parameters)
+
+		require.NotNil(t, operation.RequestBody)
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/testCreateInvoice"}, operation.RequestBody.Content["application/json"].Schema)
+		require.Contains(t, operation.Responses, "201")
+		assert.Equal(t, "Created", operation.Responses["201"].Description)
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/testInvoice"}, operation.Responses["201"].Content["application/json"].Schema)
+	})
+
+	t.Run("request schema", func(t *testing.T) {
+		schema := doc.Components.Schemas["testCreateInvoice"]
+		require.NotNil(t, schema)
+		assert.Equal(t, []string{"title", "address"}, schema.Required)
+		assert.NotContains(t, schema.Properties, "CustomerID", "parameters are not part of the body")
+		assert.NotContains(t, schema.Properties, "internal")
+		assert.Equal(t, &Schema{Type: "string", MaxLength: intPtr(100)}, schema.Properties["title"])
+		assert.Equal(t, &Schema{Type: "number", Minimum: float64Ptr(0), ExclusiveMinimum: true}, schema.Properties["total"])
+		assert.Equal(t, &Schema{Type: "string", Enum: []interface{}{"EUR", "USD"}}, schema.Properties["currency"])
+		assert.Equal(t, &Schema{Type: "string", Format: "email"}, schema.Properties["email"])
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/testAddress"}, schema.Properties["address"])
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Type: "string"}, MinItems: intPtr(1)}, schema.Properties["items"])
+		assert.Equal(t, &Schema{Type: "string", Format: "date-time", Nullable: true}, schema.Properties["dueDate"])
+		assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "integer", Format: "int64"}}, schema.Properties["metadata"])
+
+		address := doc.Components.Schemas["testAddress"]
+		require.NotNil(t, address)
+		assert.Equal(t, []string{"street"}, address.Required)
+		assert.Equal(t, &Schema{Type: "string", MinLength: intPtr(5), MaxLength: intPtr(5)}, address.Properties["zip"])
+	})
+
+	t.Run("query parameters without body", func(t *testing.T) {
+		operation := doc.Paths["/invoices"].Get
+		require.NotNil(t, operation)
+		assert.Nil(t, operation.RequestBody)
+		assert.Equal(t, []Parameter{
+			{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int64", Minimum: float64Ptr(1), Maximum: float64Ptr(100)}},
+			{Name: "status", In: "query", Schema: &Schema{Type: "array", Items: &Schema{Type: "string"}}},
+		}, operation.Parameters)
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/testInvoice"}},
+			operation.Responses["200"].Content["application/json"].Schema)
+	})
+
+	t.Run("route without description", func(t *testing.T) {
+		operation := doc.Paths["/invoices/{id}"].Delete
+		require.NotNil(t, operation)
+		assert.Equal(t, []Parameter{{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}}}, operation.Parameters)
+		assert.Equal(t, map[string]*Response{"200": {Description: "OK"}}, operation.Responses)
+	})
+}
+
+func TestRegister(t *testing.T) {
+	e, _ := newTestServer(t)
+	req := httptest.NewRequest(http.MethodGet, Route, nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	doc := &Document{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), doc))
+	assert.Contains(t, doc.Paths, "/invoices")
+}
+
+func TestAssertUpToDate(t *testing.T) {
+	e, g := newTestServer(t)
+	AssertUpToDate(t, g, e, filepath.Join("testdata", "openapi.json"))
+
+	t.Run("stale document", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "openapi.json")
+		require.NoError(t, os.WriteFile(file, []byte(`{"openapi":"3.0.3","paths":{}}`), 0644))
+
+		mockT := &recordingT{}
+		AssertUpToDate(mockT, g, e, file)
+		assert.True(t, mockT.failed)
+	})
+
+	t.Run("missing document", func(t *testing.T) {
+		mockT := &recordingT{}
+		AssertUpToDate(mockT, g, e, filepath.Join(t.TempDir(), "openapi.json"))
+		assert.True(t, mockT.failed)
+	})
+
+	t.Run("formatting is ignored", func(t *testing.T) {
+		generated, err := g.Document(e).JSON()
+		require.NoError(t, err)
+		compact := &bytes.Buffer{}
+		require.NoError(t, json.Compact(compact, generated))
+		file := filepath.Join(t.TempDir(), "openapi.json")
+		require.NoError(t, os.WriteFile(file, compact.Bytes(), 0644))
+
+		mockT := &recordingT{}
+		AssertUpToDate(mockT, g, e, file)
+		assert.False(t, mockT.failed)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "openapi.json")
+		t.Setenv(UpdateEnv, "true")
+		AssertUpToDate(t, g, e, file)
+
+		t.Setenv(UpdateEnv, "")
+		AssertUpToDate(t, g, e, file)
+	})
+}
+
+// recordingT records whether an assertion failed instead of failing the test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failed = true
+}
+
+func (r *recordingT) FailNow() {
+	r.failed = true
+}
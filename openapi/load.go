@@ -0,0 +1,75 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load reads an OpenAPI 3 document from a JSON or YAML file, the format is determined by the file extension.
+func Load(file string) (*Document, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI document: %w", err)
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yaml" || ext == ".yml" {
+		return ParseYAML(data)
+	}
+	return Parse(data)
+}
+
+// Parse parses an OpenAPI 3 document in JSON format.
+func Parse(data []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, errors.New("failed to parse OpenAPI document: only OpenAPI 3 is supported")
+	}
+	return doc, nil
+}
+
+// ParseYAML parses an OpenAPI 3 document in YAML format.
+func ParseYAML(data []byte) (*Document, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	jsonData, err := json.Marshal(jsonCompatible(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	return Parse(jsonData)
+}
+
+// jsonCompatible converts the maps with non-string keys created by the YAML decoder, e.g. for status codes
+// like 200, into maps with string keys.
+func jsonCompatible(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = jsonCompatible(elem)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			converted[fmt.Sprint(key)] = jsonCompatible(elem)
+		}
+		return converted
+	case []interface{}:
+		for idx, elem := range v {
+			v[idx] = jsonCompatible(elem)
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,361 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+var templateParamPattern = regexp.MustCompile(`\{([^/}]+)\}`)
+
+// MiddlewareConfig configures the validation middleware.
+type MiddlewareConfig struct {
+	// ValidateResponses also validates the JSON bodies of the responses. Invalid responses are replaced by
+	// a 500 error. This is meant for tests because all responses are buffered.
+	ValidateResponses bool
+	// Skipper defines requests that are not validated (optional).
+	Skipper func(c echo.Context) bool
+}
+
+// route is a path of the document with the regular expression that matches it.
+type route struct {
+	item       *PathItem
+	pattern    *regexp.Regexp
+	paramNames []string
+}
+
+// validator validates requests and responses against a document.
+type validator struct {
+	routes  []route
+	schemas *schemaValidator
+}
+
+// Middleware returns a middleware that validates the path parameters, query parameters, headers and JSON bodies of
+// requests against the operations of the document. Invalid requests receive the same 400 response with
+// a server.ValidationError as requests that fail the validation of the server.Binder. Requests for paths or methods
+// that are not part of the document are not validated. References are only supported for schemas.
+func Middleware(doc *Document, config MiddlewareConfig) echo.MiddlewareFunc {
+	v := newValidator(doc)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper != nil && config.Skipper(c) {
+				return next(c)
+			}
+			operation, params, pathParams := v.find(c.Request())
+			if operation == nil {
+				return next(c)
+			}
+			if err := v.validateRequest(c, operation, params, pathParams); err != nil {
+				return err
+			}
+			if !config.ValidateResponses {
+				return next(c)
+			}
+			return v.validateResponse(c, next, operation)
+		}
+	}
+}
+
+func newValidator(doc *Document) *validator {
+	v := &validator{schemas: &schemaValidator{doc: doc}}
+	for path, item := range doc.Paths {
+		paramNames := []string{}
+		pattern := regexp.QuoteMeta(path)
+		for _, match := range templateParamPattern.FindAllStringSubmatch(path, -1) {
+			paramNames = append(paramNames, match[1])
+			pattern = strings.Replace(pattern, regexp.QuoteMeta(match[0]), "([^/]+)", 1)
+		}
+		v.routes = append(v.routes, route{item: item, pattern: regexp.MustCompile("^" + pattern + "$"), paramNames: paramNames})
+	}
+	// Paths without parameters take precedence over paths with parameters, e.g. /invoices/latest over /invoices/{id}.
+	sort.Slice(v.routes, func(i, j int) bool {
+		if len(v.routes[i].paramNames) != len(v.routes[j].paramNames) {
+			return len(v.routes[i].paramNames) < len(v.routes[j].paramNames)
+		}
+		return v.routes[i].pattern.String() < v.routes[j].pattern.String()
+	})
+	return v
+}
+
+// find returns the operation of the request, its parameters including those of the path item
+// and the values of the path parameters.
+func (v *validator) find(req *http.Request) (*Operation, []Parameter, map[string]string) {
+	for _, r := range v.routes {
+		matches := r.pattern.FindStringSubmatch(req.URL.Path)
+		if matches == nil {
+			continue
+		}
+		operation := r.item.Operation(req.Method)
+		if operation == nil {
+			return nil, nil, nil
+		}
+		pathParams := map[string]string{}
+		for idx, name := range r.paramNames {
+			pathParams[name] = matches[idx+1]
+		}
+		return operation, mergeParameters(r.item.Parameters, operation.Parameters), pathParams
+	}
+	return nil, nil, nil
+}
+
+// mergeParameters returns the parameters of the path item, parameters of the operation with the same name
+// and location replace them.
+func mergeParameters(pathParams []Parameter, operationParams []Parameter) []Parameter {
+	params := append([]Parameter{}, operationParams...)
+	for _, pathParam := range pathParams {
+		overridden := false
+		for _, param := range operationParams {
+			overridden = overridden || param.Name == pathParam.Name && param.In == pathParam.In
+		}
+		if !overridden {
+			params = append(params, pathParam)
+		}
+	}
+	return params
+}
+
+func (v *validator) validateRequest(c echo.Context, operation *Operation, params []Parameter, pathParams map[string]string) error {
+	details := []server.FieldError{}
+	for _, param := range params {
+		details = append(details, v.validateParameter(c, param, pathParams)...)
+	}
+
+	bodyDetails, err := v.validateBody(c, operation.RequestBody)
+	if err != nil {
+		return err
+	}
+	details = append(details, bodyDetails...)
+
+	if len(details) > 0 {
+		return server.NewFieldValidationError(details)
+	}
+	return nil
+}
+
+func (v *validator) validateParameter(c echo.Context, param Parameter, pathParams map[string]string) []server.FieldError {
+	var values []string
+	switch param.In {
+	case "path":
+		values = []string{pathParams[param.Name]}
+	case "query":
+		values = c.QueryParams()[param.Name]
+	case "header":
+		values = c.Request().Header.Values(param.Name)
+	default:
+		return nil
+	}
+
+	if len(values) == 0 {
+		if param.Required {
+			return []server.FieldError{{Field: param.Name, Rule: ruleRequired}}
+		}
+		return nil
+	}
+	schema := v.schemas.resolve(param.Schema)
+	if schema == nil {
+		return nil
+	}
+	value, ok := parameterValue(schema, values, v.schemas)
+	if !ok {
+		return []server.FieldError{{Field: param.Name, Rule: ruleType, Param: schema.Type}}
+	}
+	return v.schemas.validate(schema, value, param.Name)
+}
+
+// parameterValue converts the values of a parameter to the type of the schema, so they can be validated like JSON.
+// Arrays use all values, the values of other types can be comma separated.
+func parameterValue(schema *Schema, values []string, schemas *schemaValidator) (interface{}, bool) {
+	if schema.Type != "array" {
+		return scalarValue(schema.Type, values[0])
+	}
+
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+	itemType := ""
+	if items := schemas.resolve(schema.Items); items != nil {
+		itemType = items.Type
+	}
+	array := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		item, ok := scalarValue(itemType, value)
+		if !ok {
+			return nil, false
+		}
+		array = append(array, item)
+	}
+	return array, true
+}
+
+func scalarValue(schemaType string, value string) (interface{}, bool) {
+	switch schemaType {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(value), true
+	case "boolean":
+		parsed, err := strconv.ParseBool(value)
+		return parsed, err == nil
+	default:
+		return value, true
+	}
+}
+
+// validateBody validates JSON bodies against the schema of the request body. The body is restored for the handler.
+func (v *validator) validateBody(c echo.Context, requestBody *RequestBody) ([]server.FieldError, error) {
+	if requestBody == nil {
+		return nil, nil
+	}
+	req := c.Request()
+	body := []byte{}
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, server.NewValidationHTTPError(err)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			return []server.FieldError{{Field: "body", Rule: ruleRequired}}, nil
+		}
+		return nil, nil
+	}
+	schema, ok := jsonSchema(requestBody.Content, req.Header.Get(echo.HeaderContentType))
+	if !ok {
+		return nil, nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return nil, server.NewValidationHTTPError(echo.NewHTTPError(http.StatusBadRequest, err.Error()))
+	}
+	details := v.schemas.validate(schema, value, "")
+	for idx := range details {
+		if details[idx].Field == "" {
+			details[idx].Field = "body"
+		}
+	}
+	return details, nil
+}
+
+// jsonSchema returns the schema for the content type if it is JSON.
+func jsonSchema(content map[string]MediaType, contentType string) (*Schema, bool) {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if mediaType != echo.MIMEApplicationJSON && !strings.HasSuffix(mediaType, "+json") {
+		return nil, false
+	}
+	if media, ok := content[mediaType]; ok {
+		return media.Schema, media.Schema != nil
+	}
+	if media, ok := content[echo.MIMEApplicationJSON]; ok {
+		return media.Schema, media.Schema != nil
+	}
+	return nil, false
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// validateResponse buffers the response of the handler and only sends it if its JSON body matches the document.
+func (v *validator) validateResponse(c echo.Context, next echo.HandlerFunc, operation *Operation) error {
+	res := c.Response()
+	header := res.Header().Clone()
+	buffer := &bufferedResponse{ResponseWriter: res.Writer, status: http.StatusOK}
+	res.Writer = buffer
+	handlerErr := next(c)
+	res.Writer = buffer.ResponseWriter
+	if !res.Committed {
+		return handlerErr
+	}
+
+	if err := v.checkResponse(operation, buffer.status, res.Header().Get(echo.HeaderContentType), buffer.body.Bytes()); err != nil {
+		// Nothing was sent yet, so the error response can be written instead. The headers set by the handler,
+		// e.g. Content-Length or ETag, are removed.
+		res.Committed = false
+		res.Size = 0
+		for key := range res.Header() {
+			delete(res.Header(), key)
+		}
+		for key, values := range header {
+			res.Header()[key] = values
+		}
+		return err
+	}
+	res.Writer.WriteHeader(buffer.status)
+	_, _ = res.Writer.Write(buffer.body.Bytes())
+	return handlerErr
+}
+
+func (v *validator) checkResponse(operation *Operation, status int, contentType string, body []byte) error {
+	response := responseForStatus(operation.Responses, status)
+	if response == nil {
+		return fmt.Errorf("response status %d is not part of the OpenAPI document", status)
+	}
+	schema, ok := jsonSchema(response.Content, contentType)
+	if !ok || len(body) == 0 {
+		return nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	if details := v.schemas.validate(schema, value, ""); len(details) > 0 {
+		return fmt.Errorf("response body does not match the OpenAPI document: %s",
+			(&server.ValidationError{Message: "invalid fields", Details: details}).String())
+	}
+	return nil
+}
+
+// responseForStatus returns the response for the status code, ranges like 2XX or the default response.
+func responseForStatus(responses map[string]*Response, status int) *Response {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		if response, ok := responses[key]; ok {
+			return response
+		}
+	}
+	return nil
+}
+
+// bufferedResponse keeps the response in memory until it was validated.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+func TestMiddleware(t *testing.T) {
+	doc, err := Load(filepath.Join("testdata", "invoices.yaml"))
+	require.NoError(t, err)
+	e := newValidatedServer(t, doc, MiddlewareConfig{})
+
+	cases := []struct {
+		name         string
+		method       string
+		target       string
+		headers      map[string]string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "valid query",
+			method:       http.MethodGet,
+			target:       "/invoices?limit=10&status=open&status=paid",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "invalid query",
+			method:       http.MethodGet,
+			target:       "/invoices?limit=500&status=open,draft",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request validation failed","details":[
+				{"field":"limit","rule":"lte","param":"100","message":"limit must be less than or equal to 100"},
+				{"field":"status[1]","rule":"oneof","param":"open paid","message":"status[1] must be one of [open paid]"}
+			]}}`,
+		},
+		{
+			name:         "wrong type of path parameter",
+			method:       http.MethodGet,
+			target:       "/invoices/abc",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request validation failed","details":[
+				{"field":"id","rule":"type","param":"integer","message":"id must be of type integer"}
+			]}}`,
+		},
+		{
+			name:         "path without parameter takes precedence",
+			method:       http.MethodGet,
+			target:       "/invoices/latest",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "valid body",
+			method:       http.MethodPost,
+			target:       "/invoices",
+			headers:      map[string]string{"X-Tenant": "acme"},
+			body:         `{"title":"Rent","total":12.5,"items":[{"quantity":2}]}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"title":"Rent","total":12.5,"items":[{"quantity":2}]}`,
+		},
+		{
+			name:         "invalid body and header",
+			method:       http.MethodPost,
+			target:       "/invoices",
+			headers:      map[string]string{"X-Tenant": "ACME"},
+			body:         `{"title":"R","total":0,"email":"nope","items":[{"quantity":1.5},{},{}],"color":"red"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request validation failed","details":[
+				{"field":"X-Tenant","rule":"pattern","param":"^[a-z]+$","message":"X-Tenant must match the pattern ^[a-z]+$"},
+				{"field":"color","rule":"unknown","message":"color is not allowed"},
+				{"field":"email","rule":"email","message":"email must be a valid email address"},
+				{"field":"items","rule":"max","param":"2","message":"items must be at most 2"},
+				{"field":"items[0].quantity","rule":"type","param":"integer","message":"items[0].quantity must be of type integer"},
+				{"field":"title","rule":"min","param":"3","message":"title must be at least 3"},
+				{"field":"total","rule":"gt","param":"0","message":"total must be greater than 0"}
+			]}}`,
+		},
+		{
+			name:         "missing body and header",
+			method:       http.MethodPost,
+			target:       "/invoices",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request validation failed","details":[
+				{"field":"X-Tenant","rule":"required","message":"X-Tenant is required"},
+				{"field":"body","rule":"required","message":"body is required"}
+			]}}`,
+		},
+		{
+			name:         "invalid JSON",
+			method:       http.MethodPost,
+			target:       "/invoices",
+			headers:      map[string]string{"X-Tenant": "acme"},
+			body:         `{"title":`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request could not be parsed","details":[]}}`,
+		},
+		{
+			name:         "data after the JSON value",
+			method:       http.MethodPost,
+			target:       "/invoices",
+			headers:      map[string]string{"X-Tenant": "acme"},
+			body:         `{"title":"Rent","total":1} garbage`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request could not be parsed","details":[]}}`,
+		},
+		{
+			name:         "route that is not documented",
+			method:       http.MethodDelete,
+			target:       "/invoices/1",
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serveRequest(e, test.method, test.target, test.headers, test.body)
+			assert.Equal(t, test.expectedCode, rec.Code, rec.Body.String())
+			if test.expectedBody != "" {
+				assert.JSONEq(t, test.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareResponses(t *testing.T) {
+	doc, err := Load(filepath.Join("testdata", "invoices.yaml"))
+	require.NoError(t, err)
+	e := newValidatedServer(t, doc, MiddlewareConfig{ValidateResponses: true})
+
+	rec := serveRequest(e, http.MethodGet, "/invoices/1", nil, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":1,"title":null}`, rec.Body.String())
+
+	rec = serveRequest(e, http.MethodGet, "/invoices/2", nil, "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "the id is missing in the response")
+	assert.Contains(t, rec.Body.String(), "response body does not match the OpenAPI document: invalid fields: id (required)")
+	assert.Empty(t, rec.Header().Get("ETag"), "the headers of the handler are not sent with the error")
+	assert.Equal(t, "nosniff", rec.Header().Get(echo.HeaderXContentTypeOptions), "the headers of other middleware are kept")
+
+	rec = serveRequest(e, http.MethodPost, "/invoices", map[string]string{"X-Tenant": "acme"}, `{"title":"Rent","total":1}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code, "status 200 is not documented")
+}
+
+func TestLoad(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "missing.yaml"))
+	assert.Error(t, err)
+
+	_, err = Parse([]byte(`{"swagger":"2.0"}`))
+	assert.EqualError(t, err, "failed to parse OpenAPI document: only OpenAPI 3 is supported")
+
+	doc, err := Load(filepath.Join("testdata", "openapi.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "Invoices", doc.Info.Title)
+
+	doc, err = Load(filepath.Join("testdata", "invoices.yaml"))
+	require.NoError(t, err)
+	require.Contains(t, doc.Paths["/invoices"].Post.Responses, "201", "status codes are converted to strings")
+	assert.True(t, isFalseSchema(doc.Components.Schemas["CreateInvoice"].AdditionalProperties))
+
+	_, err = ParseYAML([]byte(`
+openapi: 3.0.3
+components:
+  schemas:
+    Invoice:
+      properties:
+        number:
+          type: string
+          pattern: "^[A-Z+$"
+`))
+	assert.ErrorContains(t, err, `invalid pattern "^[A-Z+$"`)
+}
+
+func TestInvalidPatternOfDocumentCreatedInCode(t *testing.T) {
+	v := &schemaValidator{doc: &Document{}}
+	errs := v.validate(&Schema{Type: "string", Pattern: "^[A-Z+$"}, "ABC", "number")
+	assert.Equal(t, []server.FieldError{{Field: "number", Rule: "pattern", Param: "^[A-Z+$"}}, errs)
+}
+
+func newValidatedServer(t *testing.T, doc *Document, config MiddlewareConfig) *echo.Echo {
+	t.Helper()
+	e, _, err := server.NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	e.Use(Middleware(doc, config))
+
+	ok := func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/invoices", ok)
+	e.GET("/invoices/latest", ok)
+	e.DELETE("/invoices/:id", ok)
+	e.GET("/invoices/:id", func(c echo.Context) error {
+		if c.Param("id") == "1" {
+			return c.JSON(http.StatusOK, map[string]interface{}{"id": 1, "title": nil})
+		}
+		c.Response().Header().Set("ETag", `"2"`)
+		return c.JSON(http.StatusOK, map[string]interface{}{"title": "Rent"})
+	})
+	e.POST("/invoices", func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.JSONBlob(http.StatusOK, body)
+	})
+	return e
+}
+
+func serveRequest(e *echo.Echo, method string, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,332 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+const componentsPrefix = "#/components/schemas/"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	amountType        = reflect.TypeOf(server.Amount{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaBuilder creates the schemas of Go types. Named structs are added to the components and referenced.
+type schemaBuilder struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{components: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schema returns the schema of the type like it is encoded by encoding/json.
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Ptr {
+		schema := b.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+	if schema := specialSchema(t); schema != nil {
+		return schema
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		return &Schema{Ref: componentsPrefix + b.component(t)}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	default:
+		return kindSchema(t.Kind())
+	}
+}
+
+// specialSchema returns the schema of types that define their own encoding or nil for other types.
+func specialSchema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == amountType:
+		return &Schema{Type: "number"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// The encoding is unknown.
+		return &Schema{}
+	case t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	return nil
+}
+
+func kindSchema(kind reflect.Kind) *Schema {
+	switch kind {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float64Ptr(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	default:
+		// Interfaces can contain any value.
+		return &Schema{}
+	}
+}
+
+// component adds the schema of the named struct to the components and returns its name.
+// If types of different packages have the same name, the package name is added.
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, exists := b.components[name]; exists {
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	b.names[t] = name
+	// The placeholder prevents endless recursion for recursive types.
+	b.components[name] = &Schema{}
+	*b.components[name] = *b.structSchema(t)
+	return name
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range bodyFields(t) {
+		property := b.schema(field.Type)
+		if applyRules(property, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, field.Name)
+		}
+		schema.Properties[field.Name] = property
+	}
+	return schema
+}
+
+// bodyFields returns the fields of a struct that are encoded to JSON, named by their JSON names.
+// Fields of embedded structs are promoted unless the outer struct has a field with the same name.
+// Fields that are only bound from path, query or header parameters are excluded.
+func bodyFields(t reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	embeddedFields := []reflect.StructField{}
+	names := map[string]bool{}
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name, embedded, ok := jsonName(field)
+		switch {
+		case !ok:
+		case embedded:
+			embeddedFields = append(embeddedFields, bodyFields(derefType(field.Type))...)
+		default:
+			field.Name = name
+			names[name] = true
+			fields = append(fields, field)
+		}
+	}
+
+	for _, field := range embeddedFields {
+		if !names[field.Name] {
+			names[field.Name] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// jsonName returns the JSON name of the field and whether it is an embedded struct whose fields are promoted.
+func jsonName(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("json")
+	name := strings.SplitN(tag, ",", 2)[0]
+	if name == "-" || !field.IsExported() && !field.Anonymous {
+		return "", false, false
+	}
+	if field.Anonymous && name == "" && derefType(field.Type).Kind() == reflect.Struct {
+		return "", true, true
+	}
+	if !field.IsExported() {
+		return "", false, false
+	}
+	if tag == "" && isParameter(field) {
+		return "", false, false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name, false, true
+}
+
+func isParameter(field reflect.StructField) bool {
+	for _, in := range parameterTags {
+		if field.Tag.Get(in.tag) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func float64Ptr(value float64) *float64 {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+// applyRules adds the constraints of the validate tag to the schema and returns whether the value is required.
+// Rules for the elements of slices (after "dive") and alternatives ("|") are not mapped. Constraints cannot be
+// added to references, so only "required" is considered for named structs.
+func applyRules(schema *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "dive" {
+			break
+		}
+		if name == "required" {
+			required = true
+			continue
+		}
+		if apply, ok := ruleMappings[name]; ok && schema.Ref == "" && !strings.Contains(rule, "|") {
+			apply(schema, param)
+		}
+	}
+	return required
+}
+
+// ruleMappings map the rules of the validator to JSON Schema constraints.
+var ruleMappings = map[string]func(schema *Schema, param string){
+	"min": func(schema *Schema, param string) {
+		setBound(schema, param, false, false)
+	},
+	"gte": func(schema *Schema, param string) {
+		setBound(schema, param, false, false)
+	},
+	"gt": func(schema *Schema, param string) {
+		setBound(schema, param, false, true)
+	},
+	"max": func(schema *Schema, param string) {
+		setBound(schema, param, true, false)
+	},
+	"lte": func(schema *Schema, param string) {
+		setBound(schema, param, true, false)
+	},
+	"lt": func(schema *Schema, param string) {
+		setBound(schema, param, true, true)
+	},
+	"len": func(schema *Schema, param string) {
+		setBound(schema, param, false, false)
+		setBound(schema, param, true, false)
+	},
+	"oneof": func(schema *Schema, param string) {
+		for _, value := range strings.Fields(param) {
+			schema.Enum = append(schema.Enum, enumValue(value, schema.Type))
+		}
+	},
+	"email":    formatRule("email"),
+	"url":      formatRule("uri"),
+	"uri":      formatRule("uri"),
+	"uuid":     formatRule("uuid"),
+	"uuid4":    formatRule("uuid"),
+	"hostname": formatRule("hostname"),
+	"ipv4":     formatRule("ipv4"),
+	"ipv6":     formatRule("ipv6"),
+	"datetime": func(schema *Schema, param string) {
+		switch param {
+		case "2006-01-02":
+			schema.Format = "date"
+		case time.RFC3339:
+			schema.Format = "date-time"
+		}
+	},
+}
+
+func formatRule(format string) func(schema *Schema, param string) {
+	return func(schema *Schema, param string) {
+		if schema.Type == "string" {
+			schema.Format = format
+		}
+	}
+}
+
+// setBound sets the minimum or maximum of numbers, the length of strings or the number of items of arrays.
+// Like the validator, exclusive bounds of lengths are converted to inclusive ones.
+func setBound(schema *Schema, param string, upper bool, exclusive bool) {
+	value, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+
+	switch schema.Type {
+	case "integer", "number":
+		if upper {
+			schema.Maximum, schema.ExclusiveMaximum = float64Ptr(value), exclusive
+		} else {
+			schema.Minimum, schema.ExclusiveMinimum = float64Ptr(value), exclusive
+		}
+	case "string", "array":
+		length := int(value)
+		if exclusive && upper {
+			length--
+		} else if exclusive {
+			length++
+		}
+		setLength(schema, length, upper)
+	}
+}
+
+func setLength(schema *Schema, length int, upper bool) {
+	switch {
+	case schema.Type == "array" && upper:
+		schema.MaxItems = intPtr(length)
+	case schema.Type == "array":
+		schema.MinItems = intPtr(length)
+	case upper:
+		schema.MaxLength = intPtr(length)
+	default:
+		schema.MinLength = intPtr(length)
+	}
+}
+
+func enumValue(value string, schemaType string) interface{} {
+	if schemaType == "integer" || schemaType == "number" {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package openapi
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+
+	"github.com/labstack/echo/v4"
+)
+
+// UpdateEnv is the environment variable that makes AssertUpToDate write the generated document to the file.
+const UpdateEnv = "UPDATE_OPENAPI"
+
+// TestingT is the part of *testing.T that is used by AssertUpToDate.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	FailNow()
+}
+
+// AssertUpToDate fails the test if the committed document in the file differs from the document generated for the
+// routes of the server, so a stale document is noticed in CI. Run the test with UPDATE_OPENAPI=true to update the file.
+func AssertUpToDate(t TestingT, g *Generator, e *echo.Echo, file string) {
+	t.Helper()
+	generated, err := g.Document(e).JSON()
+	if err != nil {
+		fatalf(t, "the OpenAPI document could not be generated: %v", err)
+		return
+	}
+
+	if os.Getenv(UpdateEnv) == "true" {
+		if err := os.WriteFile(file, generated, 0644); err != nil {
+			fatalf(t, "the OpenAPI document could not be written: %v", err)
+		}
+		return
+	}
+
+	committed, err := os.ReadFile(file)
+	if err != nil {
+		fatalf(t, "the OpenAPI document could not be read, run the test with %s=true to create it: %v", UpdateEnv, err)
+		return
+	}
+	if !jsonEqual(committed, generated) {
+		t.Errorf("the OpenAPI document %s is stale, run the test with %s=true to update it", file, UpdateEnv)
+	}
+}
+
+func fatalf(t TestingT, format string, args ...interface{}) {
+	t.Helper()
+	t.Errorf(format, args...)
+	t.FailNow()
+}
+
+// jsonEqual reports whether both documents contain the same JSON values, independent of the formatting.
+func jsonEqual(a, b []byte) bool {
+	var valueA, valueB interface{}
+	if json.Unmarshal(a, &valueA) != nil || json.Unmarshal(b, &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
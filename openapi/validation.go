@@ -0,0 +1,274 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fastbill/go-service-toolkit/v4/server"
+)
+
+// The rules of the field errors are named like the rules of the validator, so the messages are translated the same way.
+const (
+	ruleRequired = "required"
+	ruleType     = "type"
+	ruleUnknown  = "unknown"
+	ruleSchema   = "schema"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// formatCheckers validate the formats of strings. Unknown formats are not checked.
+var formatCheckers = map[string]struct {
+	rule  string
+	param string
+	valid func(value string) bool
+}{
+	"date-time": {rule: "datetime", param: time.RFC3339, valid: func(value string) bool {
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	}},
+	"date": {rule: "datetime", param: "2006-01-02", valid: func(value string) bool {
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	}},
+	"email": {rule: "email", valid: func(value string) bool {
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	}},
+	"uri": {rule: "url", valid: func(value string) bool {
+		parsed, err := url.Parse(value)
+		return err == nil && parsed.Scheme != ""
+	}},
+	"uuid": {rule: "uuid", valid: uuidPattern.MatchString},
+}
+
+// schemaValidator validates decoded JSON values against the schemas of a document.
+type schemaValidator struct {
+	doc      *Document
+	patterns sync.Map
+}
+
+// validate returns the field errors of the value. Numbers need to be decoded as json.Number.
+func (v *schemaValidator) validate(schema *Schema, value interface{}, path string) []server.FieldError {
+	schema = v.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	if errs := v.validateCombinations(schema, value, path); len(errs) > 0 {
+		return errs
+	}
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return []server.FieldError{{Field: path, Rule: ruleType, Param: schema.Type}}
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return v.validateObject(schema, typed, path)
+	case []interface{}:
+		return v.validateArray(schema, typed, path)
+	case string:
+		return v.validateString(schema, typed, path)
+	case json.Number:
+		return validateNumber(schema, typed, path)
+	case bool:
+		return validateType(schema, "boolean", path)
+	}
+	return nil
+}
+
+// resolve follows the reference to the components of the document.
+func (v *schemaValidator) resolve(schema *Schema) *Schema {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < 32; depth++ {
+		if v.doc.Components == nil {
+			return nil
+		}
+		schema = v.doc.Components.Schemas[strings.TrimPrefix(schema.Ref, componentsPrefix)]
+	}
+	return schema
+}
+
+func (v *schemaValidator) validateCombinations(schema *Schema, value interface{}, path string) []server.FieldError {
+	errs := []server.FieldError{}
+	for _, sub := range schema.AllOf {
+		errs = append(errs, v.validate(sub, value, path)...)
+	}
+
+	matches := func(schemas []*Schema) int {
+		count := 0
+		for _, sub := range schemas {
+			if len(v.validate(sub, value, path)) == 0 {
+				count++
+			}
+		}
+		return count
+	}
+	if len(schema.AnyOf) > 0 && matches(schema.AnyOf) == 0 ||
+		len(schema.OneOf) > 0 && matches(schema.OneOf) != 1 ||
+		schema.Not != nil && len(v.validate(schema.Not, value, path)) == 0 {
+		errs = append(errs, server.FieldError{Field: path, Rule: ruleSchema})
+	}
+	return errs
+}
+
+func validateType(schema *Schema, valueType string, path string) []server.FieldError {
+	if schema.Type != "" && schema.Type != valueType {
+		return []server.FieldError{{Field: path, Rule: ruleType, Param: schema.Type}}
+	}
+	return nil
+}
+
+func (v *schemaValidator) validateObject(schema *Schema, object map[string]interface{}, path string) []server.FieldError {
+	if errs := validateType(schema, "object", path); errs != nil {
+		return errs
+	}
+
+	errs := []server.FieldError{}
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, server.FieldError{Field: joinPath(path, name), Rule: ruleRequired})
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, ok := schema.Properties[name]
+		if !ok {
+			property = schema.AdditionalProperties
+		}
+		if isFalseSchema(property) {
+			errs = append(errs, server.FieldError{Field: joinPath(path, name), Rule: ruleUnknown})
+			continue
+		}
+		errs = append(errs, v.validate(property, object[name], joinPath(path, name))...)
+	}
+	return errs
+}
+
+// isFalseSchema reports whether the schema does not allow any value, e.g. for "additionalProperties": false.
+func isFalseSchema(schema *Schema) bool {
+	return schema != nil && schema.Not != nil && reflect.DeepEqual(*schema.Not, Schema{})
+}
+
+func (v *schemaValidator) validateArray(schema *Schema, array []interface{}, path string) []server.FieldError {
+	if errs := validateType(schema, "array", path); errs != nil {
+		return errs
+	}
+
+	errs := validateLength(len(array), schema.MinItems, schema.MaxItems, path)
+	for idx, elem := range array {
+		errs = append(errs, v.validate(schema.Items, elem, fmt.Sprintf("%s[%d]", path, idx))...)
+	}
+	return errs
+}
+
+func (v *schemaValidator) validateString(schema *Schema, value string, path string) []server.FieldError {
+	if errs := validateType(schema, "string", path); errs != nil {
+		return errs
+	}
+
+	errs := validateLength(utf8.RuneCountInString(value), schema.MinLength, schema.MaxLength, path)
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		errs = append(errs, enumError(schema, path))
+	}
+	if schema.Pattern != "" && !v.matchPattern(schema.Pattern, value) {
+		errs = append(errs, server.FieldError{Field: path, Rule: "pattern", Param: schema.Pattern})
+	}
+	if checker, ok := formatCheckers[schema.Format]; ok && !checker.valid(value) {
+		errs = append(errs, server.FieldError{Field: path, Rule: checker.rule, Param: checker.param})
+	}
+	return errs
+}
+
+// matchPattern compiles the regular expression once. Loaded documents only contain valid patterns, invalid patterns
+// of documents created in code match nothing so the validation is not turned off.
+func (v *schemaValidator) matchPattern(pattern string, value string) bool {
+	cached, ok := v.patterns.Load(pattern)
+	if !ok {
+		// Compile returns nil for invalid patterns.
+		compiled, _ := regexp.Compile(pattern)
+		cached, _ = v.patterns.LoadOrStore(pattern, compiled)
+	}
+	compiled := cached.(*regexp.Regexp)
+	return compiled != nil && compiled.MatchString(value)
+}
+
+func validateLength(length int, min *int, max *int, path string) []server.FieldError {
+	errs := []server.FieldError{}
+	if min != nil && length < *min {
+		errs = append(errs, server.FieldError{Field: path, Rule: "min", Param: strconv.Itoa(*min)})
+	}
+	if max != nil && length > *max {
+		errs = append(errs, server.FieldError{Field: path, Rule: "max", Param: strconv.Itoa(*max)})
+	}
+	return errs
+}
+
+func validateNumber(schema *Schema, value json.Number, path string) []server.FieldError {
+	number, err := value.Float64()
+	isInteger := err == nil && number == math.Trunc(number)
+	if schema.Type != "" && schema.Type != "number" && !(schema.Type == "integer" && isInteger) {
+		return []server.FieldError{{Field: path, Rule: ruleType, Param: schema.Type}}
+	}
+
+	errs := []server.FieldError{}
+	if schema.Minimum != nil && (number < *schema.Minimum || schema.ExclusiveMinimum && number == *schema.Minimum) {
+		errs = append(errs, boundError(path, "gt", "gte", schema.ExclusiveMinimum, *schema.Minimum))
+	}
+	if schema.Maximum != nil && (number > *schema.Maximum || schema.ExclusiveMaximum && number == *schema.Maximum) {
+		errs = append(errs, boundError(path, "lt", "lte", schema.ExclusiveMaximum, *schema.Maximum))
+	}
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, number) {
+		errs = append(errs, enumError(schema, path))
+	}
+	return errs
+}
+
+func boundError(path string, exclusiveRule string, inclusiveRule string, exclusive bool, bound float64) server.FieldError {
+	rule := inclusiveRule
+	if exclusive {
+		rule = exclusiveRule
+	}
+	return server.FieldError{Field: path, Rule: rule, Param: strconv.FormatFloat(bound, 'f', -1, 64)}
+}
+
+func enumError(schema *Schema, path string) server.FieldError {
+	values := make([]string, 0, len(schema.Enum))
+	for _, value := range schema.Enum {
+		values = append(values, fmt.Sprint(value))
+	}
+	return server.FieldError{Field: path, Rule: "oneof", Param: strings.Join(values, " ")}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, allowed := range values {
+		if allowed == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// Routes of the admin server. Additionally the log level endpoints are registered under LogLevelRoute
+// and the health endpoints if the admin server is used together with WithHealth.
+const (
+	AdminMetricsRoute   = "/metrics"
+	AdminBuildInfoRoute = "/buildinfo"
+	AdminPprofRoute     = "/debug/pprof"
+)
+
+// AdminConfig configures the admin server.
+type AdminConfig struct {
+	// Address the admin server listens on, e.g. ":9090". It should not be reachable from the internet.
+	Address string
+	// LogLevelRevertAfter is the default duration after which a log level change is reverted, see NewLogLevelHandler.
+	LogLevelRevertAfter time.Duration
+}
+
+// AdminServer hosts the operational endpoints (pprof, metrics, build info, log level and health) on a separate port,
+// so they are not exposed on the public port. Additional endpoints can be registered on the embedded echo server.
+type AdminServer struct {
+	*echo.Echo
+	obs *observance.Obs
+}
+
+// BuildInfo is the response of the build info endpoint.
+type BuildInfo struct {
+	AppName   string `json:"appName,omitempty"`
+	Version   string `json:"version,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"buildTime,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// adminServers contains the admin servers of the servers created by NewWithOptions, so Start can start them.
+var adminServers sync.Map
+
+// NewAdminServer creates the admin server and opens its listener, so an address that is already in use is reported
+// right away. When it is passed to NewWithOptions via WithAdminServer, it is started by Start together with the main
+// server and shut down together with it. Additional endpoints need to be registered before that.
+//
+// The metrics endpoint serves the metrics of obs.Metrics if they were set up via observance.NewObs,
+// otherwise the metrics of the default Prometheus registry. The build info contains the version of observance.Config.
+func NewAdminServer(obs *observance.Obs, config AdminConfig) (*AdminServer, error) {
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on admin address: %w", err)
+	}
+
+	admin := &AdminServer{Echo: newEcho(obs, ErrorResponseConfig{}), obs: obs}
+	admin.Listener = listener
+	admin.Server.ReadHeaderTimeout = defaultTimeout
+	// There is no write timeout because CPU profiles and traces take 30 seconds by default.
+	admin.Server.IdleTimeout = defaultIdleTimeout
+	admin.Use(Recover(obs))
+
+	admin.GET(AdminMetricsRoute, echo.WrapHandler(metricsHandler(obs)))
+	admin.GET(AdminBuildInfoRoute, admin.buildInfo)
+	registerPprof(admin.Echo)
+	NewLogLevelHandler(obs, config.LogLevelRevertAfter).Register(admin.Echo)
+
+	return admin, nil
+}
+
+// Addr returns the address the admin server listens on, e.g. to find out the port if it was set to 0.
+func (a *AdminServer) Addr() net.Addr {
+	return a.Listener.Addr()
+}
+
+// serve starts the admin server in the background.
+func (a *AdminServer) serve() {
+	go func() {
+		err := a.Start(a.Listener.Addr().String())
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.obs.Logger.WithError(err).Error("admin server failed")
+		}
+	}()
+}
+
+// shutdown stops the admin server gracefully. The listener is closed explicitly because it is not closed
+// by the shutdown if the admin server was never started.
+func (a *AdminServer) shutdown(ctx context.Context) error {
+	err := a.Shutdown(ctx)
+	if closeErr := a.Listener.Close(); closeErr != nil && !errors.Is(closeErr, net.ErrClosed) && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (a *AdminServer) buildInfo(c echo.Context) error {
+	info := BuildInfo{
+		AppName:   a.obs.AppName(),
+		Version:   a.obs.Version(),
+		GoVersion: runtime.Version(),
+	}
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.time":
+				info.BuildTime = setting.Value
+			case "vcs.modified":
+				info.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return c.JSON(http.StatusOK, info)
+}
+
+func metricsHandler(obs *observance.Obs) http.Handler {
+	if metrics, ok := obs.Metrics.(interface{ Handler() http.Handler }); ok {
+		return metrics.Handler()
+	}
+	return promhttp.Handler()
+}
+
+// registerPprof adds the pprof endpoints, the named profiles like heap and goroutine are served by the index handler.
+// The index without trailing slash redirects, so the relative links of the index page work.
+func registerPprof(e *echo.Echo) {
+	e.GET(AdminPprofRoute, func(c echo.Context) error {
+		return c.Redirect(http.StatusMovedPermanently, AdminPprofRoute+"/")
+	})
+	e.GET(AdminPprofRoute+"/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	e.GET(AdminPprofRoute+"/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	e.GET(AdminPprofRoute+"/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	e.GET(AdminPprofRoute+"/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.POST(AdminPprofRoute+"/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	e.GET(AdminPprofRoute+"/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/health"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
+)
+
+func TestAdminServer(t *testing.T) {
+	obs, err := observance.NewObs(observance.Config{AppName: "test-app", Version: "1.2.3", LogLevel: "info"})
+	require.NoError(t, err)
+	obs.Logger = observance.NewTestLogger()
+
+	admin, err := NewAdminServer(obs, AdminConfig{Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+	m := shutdown.New(shutdown.Config{}, obs.Logger)
+	h := health.New(health.Config{})
+	e, connsClosed, err := NewWithOptions(obs, WithAdminServer(admin), WithHealth(h), WithShutdownManager(m))
+	require.NoError(t, err)
+	started := make(chan error, 1)
+	go func() {
+		started <- Start(e, "127.0.0.1:0")
+	}()
+
+	baseURL := "http://" + admin.Addr().String()
+	client := &http.Client{Timeout: 5 * time.Second}
+	get := func(t *testing.T, path string) (int, string) {
+		t.Helper()
+		resp, err := client.Get(baseURL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("build info", func(t *testing.T) {
+		status, body := get(t, AdminBuildInfoRoute)
+		assert.Equal(t, http.StatusOK, status)
+		info := BuildInfo{}
+		require.NoError(t, json.Unmarshal([]byte(body), &info))
+		assert.Equal(t, "test-app", info.AppName)
+		assert.Equal(t, "1.2.3", info.Version)
+		assert.Equal(t, runtime.Version(), info.GoVersion)
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		status, body := get(t, AdminMetricsRoute)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "go_goroutines", "the default registry is used without metrics URL")
+	})
+
+	t.Run("pprof", func(t *testing.T) {
+		status, body := get(t, AdminPprofRoute+"/")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "goroutine")
+
+		status, body = get(t, AdminPprofRoute)
+		assert.Equal(t, http.StatusOK, status, "redirected to the index")
+		assert.Contains(t, body, "goroutine")
+
+		status, body = get(t, AdminPprofRoute+"/goroutine?debug=1")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "goroutine profile")
+
+		status, _ = get(t, AdminPprofRoute+"/cmdline")
+		assert.Equal(t, http.StatusOK, status)
+	})
+
+	t.Run("log level", func(t *testing.T) {
+		status, body := get(t, LogLevelRoute)
+		assert.Equal(t, http.StatusOK, status)
+		assert.JSONEq(t, `{"level":"debug"}`, body)
+	})
+
+	t.Run("health", func(t *testing.T) {
+		status, _ := get(t, health.ReadinessRoute)
+		assert.Equal(t, http.StatusOK, status)
+
+		rec := serve(e, health.ReadinessRoute, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code, "the health endpoints are not exposed on the main server")
+		rec = serve(e, AdminMetricsRoute, nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		m.Shutdown()
+		require.NoError(t, m.Wait())
+		select {
+		case <-connsClosed:
+		case <-time.After(time.Second):
+			assert.Fail(t, "connections closed channel was not closed")
+		}
+
+		_, err := client.Get(baseURL + AdminBuildInfoRoute)
+		assert.Error(t, err, "the admin server was shut down")
+		assert.ErrorIs(t, <-started, http.ErrServerClosed)
+	})
+}
+
+func TestAdminServerLifecycle(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+	client := &http.Client{Timeout: 100 * time.Millisecond}
+
+	t.Run("not served before Start", func(t *testing.T) {
+		admin, err := NewAdminServer(obs, AdminConfig{Address: "127.0.0.1:0"})
+		require.NoError(t, err)
+		m := shutdown.New(shutdown.Config{}, obs.Logger)
+		_, _, err = NewWithOptions(obs, WithAdminServer(admin), WithShutdownManager(m))
+		require.NoError(t, err)
+
+		_, err = client.Get("http://" + admin.Addr().String() + AdminBuildInfoRoute)
+		assert.Error(t, err)
+
+		m.Shutdown()
+		require.NoError(t, m.Wait())
+		_, err = net.Dial("tcp", admin.Addr().String())
+		assert.Error(t, err, "the listener is closed by the shutdown")
+	})
+
+	t.Run("closed if the server cannot be created", func(t *testing.T) {
+		admin, err := NewAdminServer(obs, AdminConfig{Address: "127.0.0.1:0"})
+		require.NoError(t, err)
+		_, _, err = NewWithOptions(obs, WithAdminServer(admin), WithTLS("missing.crt", "missing.key"))
+		require.Error(t, err)
+
+		_, err = net.Dial("tcp", admin.Addr().String())
+		assert.Error(t, err)
+	})
+
+	t.Run("closed if the server cannot be started", func(t *testing.T) {
+		admin, err := NewAdminServer(obs, AdminConfig{Address: "127.0.0.1:0"})
+		require.NoError(t, err)
+		m := shutdown.New(shutdown.Config{}, obs.Logger)
+		e, _, err := NewWithOptions(obs, WithAdminServer(admin), WithShutdownManager(m))
+		require.NoError(t, err)
+		defer func() {
+			m.Shutdown()
+			_ = m.Wait()
+		}()
+
+		err = Start(e, "invalid address")
+		require.Error(t, err)
+		_, err = net.Dial("tcp", admin.Addr().String())
+		assert.Error(t, err)
+	})
+}
+
+func TestAdminServerErrors(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+	admin, err := NewAdminServer(obs, AdminConfig{Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer func() {
+		_ = admin.Shutdown(context.Background())
+		_ = admin.Listener.Close()
+	}()
+
+	_, err = NewAdminServer(obs, AdminConfig{Address: admin.Addr().String()})
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "failed to listen on admin address"))
+}
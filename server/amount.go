@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxAmountDigits is the maximum number of digits of an Amount, so the unscaled value fits into an int64.
+const maxAmountDigits = 18
+
+var amountType = reflect.TypeOf(Amount{})
+
+// Amount is a decimal number like a price or a quantity that is parsed without the rounding errors of float64.
+// It can be bound from JSON numbers and strings as well as from path, query and header parameters.
+// The zero value is 0.
+type Amount struct {
+	unscaled int64
+	scale    int
+}
+
+// ParseAmount parses a decimal number like "-12.50". Exponents are not supported and at most 18 digits are allowed.
+func ParseAmount(value string) (Amount, error) {
+	digits := value
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+	integerPart, fractionPart, hasPoint := strings.Cut(digits, ".")
+	if integerPart == "" && fractionPart == "" || hasPoint && fractionPart == "" ||
+		!isDigits(integerPart) || !isDigits(fractionPart) {
+		return Amount{}, fmt.Errorf("%q is not a decimal number", value)
+	}
+	if len(integerPart)+len(fractionPart) > maxAmountDigits {
+		return Amount{}, fmt.Errorf("%q has more than %d digits", value, maxAmountDigits)
+	}
+
+	unscaled, err := strconv.ParseInt(integerPart+fractionPart, 10, 64)
+	if err != nil {
+		return Amount{}, fmt.Errorf("%q is not a decimal number", value)
+	}
+	if strings.HasPrefix(value, "-") {
+		unscaled = -unscaled
+	}
+	return Amount{unscaled: unscaled, scale: len(fractionPart)}, nil
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the amount with all decimal places that were parsed, e.g. "12.50".
+func (a Amount) String() string {
+	digits := strconv.FormatInt(a.unscaled, 10)
+	sign := ""
+	if a.unscaled < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if a.scale == 0 {
+		return sign + digits
+	}
+	if len(digits) <= a.scale {
+		digits = strings.Repeat("0", a.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-a.scale] + "." + digits[len(digits)-a.scale:]
+}
+
+// Float64 returns the amount as float, e.g. for validation rules like gt=0.
+func (a Amount) Float64() float64 {
+	return float64(a.unscaled) / math.Pow10(a.scale)
+}
+
+// MinorUnits returns the amount as integer in minor units with the given number of decimal places,
+// e.g. the cents for 2. An error is returned if the amount has more decimal places or is too large.
+func (a Amount) MinorUnits(decimals int) (int64, error) {
+	if a.scale > decimals {
+		factor := int64(math.Pow10(a.scale - decimals))
+		if a.unscaled%factor != 0 {
+			return 0, fmt.Errorf("%s has more than %d decimal places", a, decimals)
+		}
+		return a.unscaled / factor, nil
+	}
+
+	if decimals-a.scale > maxAmountDigits {
+		return 0, fmt.Errorf("%s is too large for %d decimal places", a, decimals)
+	}
+	factor := int64(math.Pow10(decimals - a.scale))
+	if a.unscaled > math.MaxInt64/factor || a.unscaled < math.MinInt64/factor {
+		return 0, fmt.Errorf("%s is too large for %d decimal places", a, decimals)
+	}
+	return a.unscaled * factor, nil
+}
+
+// MarshalJSON writes the amount as JSON number with all decimal places.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts JSON numbers and strings. Like encoding/json does for other non-pointer types,
+// null leaves the amount unchanged.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	jsonType := "number"
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		jsonType = "string"
+	}
+	parsed, err := ParseAmount(value)
+	if err != nil {
+		// The type error lets the JSON decoder add the name of the field.
+		return &json.UnmarshalTypeError{Value: jsonType, Type: amountType}
+	}
+	*a = parsed
+	return nil
+}
+
+// UnmarshalText parses the amount, it is used for path, query and header parameters.
+func (a *Amount) UnmarshalText(text []byte) error {
+	parsed, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAmount(t *testing.T) {
+	valid := map[string]string{
+		"12.50":  "12.50",
+		"-0.05":  "-0.05",
+		"+3":     "3",
+		".5":     "0.5",
+		"007.10": "7.10",
+	}
+	for value, expected := range valid {
+		amount, err := ParseAmount(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, amount.String())
+	}
+
+	for _, value := range []string{"", "-", "1.", "1e5", "1,5", "abc", "-+5", "+-5", "--5", "1234567890123456789"} {
+		_, err := ParseAmount(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestAmountMinorUnits(t *testing.T) {
+	amount, err := ParseAmount("12.5")
+	require.NoError(t, err)
+
+	cents, err := amount.MinorUnits(2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1250), cents)
+	assert.Equal(t, 12.5, amount.Float64())
+
+	_, err = amount.MinorUnits(0)
+	assert.Error(t, err, "decimal places would get lost")
+
+	amount, err = ParseAmount("12.00")
+	require.NoError(t, err)
+	units, err := amount.MinorUnits(0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), units)
+
+	amount, err = ParseAmount("123456789012345678")
+	require.NoError(t, err)
+	_, err = amount.MinorUnits(2)
+	assert.Error(t, err, "overflow")
+}
+
+func TestAmountJSON(t *testing.T) {
+	payload := struct {
+		Price Amount `json:"price"`
+		Tax   Amount `json:"tax"`
+	}{}
+	require.NoError(t, json.Unmarshal([]byte(`{"price":19.99,"tax":"3.80"}`), &payload))
+	assert.Equal(t, "19.99", payload.Price.String())
+	assert.Equal(t, "3.80", payload.Tax.String())
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price":19.99,"tax":3.80}`, string(data))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"price":null,"tax":"1"}`), &payload))
+	assert.Equal(t, "19.99", payload.Price.String(), "null leaves the amount unchanged")
+	assert.Equal(t, "1", payload.Tax.String())
+
+	err = json.Unmarshal([]byte(`{"price":"cheap"}`), &payload)
+	typeErr := &json.UnmarshalTypeError{}
+	assert.ErrorAs(t, err, &typeErr)
+}
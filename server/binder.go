@@ -0,0 +1,466 @@
+package server
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	goValidator "github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// Struct tags that define from which part of the request a field is bound.
+const (
+	tagParam  = "param"
+	tagQuery  = "query"
+	tagHeader = "header"
+)
+
+// Rules of the field errors that occur during binding.
+const (
+	ruleType    = "type"
+	ruleUnknown = "unknown"
+)
+
+var (
+	errUnsupportedType = errors.New("type is not supported for binding")
+
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// BinderConfig configures the Binder.
+type BinderConfig struct {
+	// DisallowUnknownFields rejects JSON bodies that contain fields which do not exist in the struct.
+	DisallowUnknownFields bool
+}
+
+// Binder binds the body, the query parameters, the headers and the path parameters of a request into one struct
+// and validates it afterwards. Fields are bound from the parameters via the tags `query`, `header` and `param`.
+// If a value is present in several places, path parameters take precedence over headers, headers over query
+// parameters and query parameters over the body.
+//
+// Besides strings, booleans and numbers, parameters can be bound into time.Time (RFC 3339 or "2006-01-02"),
+// time.Duration, Amount and all types implementing encoding.TextUnmarshaler or echo.BindUnmarshaler,
+// also as pointers and slices. All binding and validation errors are returned together as 400 HTTPError
+// with a ValidationError as message.
+type Binder struct {
+	config BinderConfig
+}
+
+// NewBinder creates a Binder, it is used by the server created via NewWithOptions.
+func NewBinder(config BinderConfig) *Binder {
+	return &Binder{config: config}
+}
+
+// Bind binds the request to the given struct and validates it afterwards, see Binder.
+// Targets that are not pointers to structs are bound via the echo.DefaultBinder.
+func (b *Binder) Bind(i interface{}, c echo.Context) error {
+	target := reflect.ValueOf(i)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		if err := (&echo.DefaultBinder{}).Bind(i, c); err != nil {
+			return NewValidationHTTPError(err)
+		}
+		return NewValidationHTTPError(c.Validate(i))
+	}
+
+	details, err := b.bindBody(i, c)
+	if err != nil {
+		return err
+	}
+	for _, source := range paramSources {
+		paramDetails, err := bindParams(target.Elem(), c, source, "")
+		if err != nil {
+			return err
+		}
+		details = append(details, paramDetails...)
+	}
+
+	return validateBound(i, c, details)
+}
+
+// validateBound validates the struct and returns the binding errors together with the validation errors.
+// Fields that could not be bound are not validated again.
+func validateBound(i interface{}, c echo.Context, bindingDetails []FieldError) error {
+	details := bindingDetails
+	err := c.Validate(i)
+	var validationErrs goValidator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		details = append(details, withoutFields(fieldErrorsFromValidation(validationErrs), bindingDetails)...)
+	} else if err != nil {
+		return err
+	}
+
+	if len(details) == 0 {
+		return nil
+	}
+	message := msgValidationFailed
+	if len(bindingDetails) > 0 {
+		message = msgBindingFailed
+	}
+	return httperrors.New(http.StatusBadRequest, &ValidationError{Message: message, Details: details})
+}
+
+func withoutFields(details []FieldError, exclude []FieldError) []FieldError {
+	excluded := map[string]bool{}
+	for _, detail := range exclude {
+		excluded[detail.Field] = true
+	}
+	result := make([]FieldError, 0, len(details))
+	for _, detail := range details {
+		if !excluded[detail.Field] {
+			result = append(result, detail)
+		}
+	}
+	return result
+}
+
+// bindBody binds JSON bodies and returns the fields with the wrong type or unknown fields.
+// Other content types are bound by the echo.DefaultBinder.
+func (b *Binder) bindBody(i interface{}, c echo.Context) ([]FieldError, error) {
+	req := c.Request()
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, NewValidationHTTPError(err)
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	contentType := strings.ToLower(req.Header.Get(echo.HeaderContentType))
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	if mediaType != echo.MIMEApplicationJSON && !strings.HasSuffix(mediaType, "+json") {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return nil, NewValidationHTTPError((&echo.DefaultBinder{}).BindBody(c, i))
+	}
+	return b.bindJSON(i, body)
+}
+
+// bindJSON decodes the body into the struct. If that fails, the body is inspected field by field,
+// so all fields with the wrong type are reported and not only the first one.
+func (b *Binder) bindJSON(i interface{}, body []byte) ([]FieldError, error) {
+	errParse := httperrors.New(http.StatusBadRequest, &ValidationError{Message: msgBindingFailed, Details: []FieldError{}})
+	if !json.Valid(body) {
+		return nil, errParse
+	}
+
+	err := json.Unmarshal(body, i)
+	if err == nil && !b.config.DisallowUnknownFields {
+		return nil, nil
+	}
+	details := inspectJSON(reflect.TypeOf(i), body, "", b.config.DisallowUnknownFields)
+	if err != nil && len(details) == 0 {
+		return nil, errParse
+	}
+	return details, nil
+}
+
+// inspectJSON compares the JSON value with the type it is bound to and returns the fields that have the wrong type
+// and optionally the fields that do not exist in the type. The paths look like "items[0].name".
+func inspectJSON(t reflect.Type, raw json.RawMessage, path string, disallowUnknown bool) []FieldError {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if bytes.Equal(raw, []byte("null")) {
+		return nil
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return inspectJSONValue(t, raw, path)
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct:
+		return inspectJSONObject(t, raw, path, disallowUnknown)
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return inspectJSONObject(t, raw, path, disallowUnknown)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8, t.Kind() == reflect.Array:
+		return inspectJSONArray(t, raw, path, disallowUnknown)
+	default:
+		return inspectJSONValue(t, raw, path)
+	}
+}
+
+func inspectJSONValue(t reflect.Type, raw json.RawMessage, path string) []FieldError {
+	if err := json.Unmarshal(raw, reflect.New(t).Interface()); err != nil {
+		return []FieldError{{Field: path, Rule: ruleType, Param: t.String()}}
+	}
+	return nil
+}
+
+func inspectJSONArray(t reflect.Type, raw json.RawMessage, path string, disallowUnknown bool) []FieldError {
+	elems := []json.RawMessage{}
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return []FieldError{{Field: path, Rule: ruleType, Param: t.String()}}
+	}
+	details := []FieldError{}
+	for idx, elem := range elems {
+		details = append(details, inspectJSON(t.Elem(), elem, fmt.Sprintf("%s[%d]", path, idx), disallowUnknown)...)
+	}
+	return details
+}
+
+func inspectJSONObject(t reflect.Type, raw json.RawMessage, path string, disallowUnknown bool) []FieldError {
+	object := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return []FieldError{{Field: path, Rule: ruleType, Param: t.String()}}
+	}
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	details := []FieldError{}
+	for _, key := range keys {
+		fieldType, ok := objectFieldType(t, key)
+		if !ok {
+			if disallowUnknown {
+				details = append(details, FieldError{Field: joinPath(path, key), Rule: ruleUnknown})
+			}
+			continue
+		}
+		details = append(details, inspectJSON(fieldType, object[key], joinPath(path, key), disallowUnknown)...)
+	}
+	return details
+}
+
+// objectFieldType returns the type of the value with the given key of a struct or a map.
+func objectFieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	if t.Kind() == reflect.Map {
+		return t.Elem(), true
+	}
+	fieldType, ok := jsonFields(t)[strings.ToLower(key)]
+	return fieldType, ok
+}
+
+// jsonFields returns the types of the fields of a struct by their lower case JSON names
+// like the JSON decoder matches them, including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			for embeddedName, embeddedType := range jsonFields(fieldType) {
+				if _, exists := fields[embeddedName]; !exists {
+					fields[embeddedName] = embeddedType
+				}
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[strings.ToLower(name)] = field.Type
+	}
+	return fields
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// paramSource provides the values of the parameters with a given tag.
+type paramSource struct {
+	tag    string
+	values func(c echo.Context, name string) []string
+}
+
+// paramSources are ordered by precedence, later sources overwrite the values of earlier ones.
+var paramSources = []paramSource{
+	{tag: tagQuery, values: func(c echo.Context, name string) []string {
+		return c.QueryParams()[name]
+	}},
+	{tag: tagHeader, values: func(c echo.Context, name string) []string {
+		return c.Request().Header.Values(name)
+	}},
+	{tag: tagParam, values: func(c echo.Context, name string) []string {
+		if value := c.Param(name); value != "" {
+			return []string{value}
+		}
+		return nil
+	}},
+}
+
+// bindParams sets the fields with the tag of the source and returns the fields whose values could not be parsed.
+// Nested structs without the tag are bound recursively, the fields of embedded structs are treated like fields
+// of the embedding struct as in JSON. The path matches the field names of the validation errors.
+func bindParams(v reflect.Value, c echo.Context, source paramSource, path string) ([]FieldError, error) {
+	details := []FieldError{}
+	t := v.Type()
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		if !field.IsExported() && !field.Anonymous {
+			continue
+		}
+
+		name := field.Tag.Get(source.tag)
+		if name == "" {
+			nestedDetails, err := bindNestedParams(v.Field(idx), field, c, source, path)
+			if err != nil {
+				return nil, err
+			}
+			details = append(details, nestedDetails...)
+			continue
+		}
+
+		values := source.values(c, name)
+		if len(values) == 0 || !v.Field(idx).CanSet() {
+			continue
+		}
+		err := setField(v.Field(idx), values)
+		if errors.Is(err, errUnsupportedType) {
+			return nil, err
+		}
+		if err != nil {
+			details = append(details, FieldError{Field: joinPath(path, name), Rule: ruleType, Param: typeName(field.Type)})
+		}
+	}
+	return details, nil
+}
+
+func bindNestedParams(v reflect.Value, field reflect.StructField, c echo.Context, source paramSource, path string) ([]FieldError, error) {
+	if v.Kind() != reflect.Struct || isValueType(field.Type) {
+		return nil, nil
+	}
+	if isEmbeddedStruct(field) {
+		return bindParams(v, c, source, path)
+	}
+	name := fieldName(field)
+	if name == "" {
+		name = field.Name
+	}
+	return bindParams(v, c, source, joinPath(path, name))
+}
+
+// isValueType reports whether the struct is bound from a single value like time.Time or Amount.
+func isValueType(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) ||
+		reflect.PtrTo(t).Implements(bindUnmarshalerType)
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	bindUnmarshalerType = reflect.TypeOf((*echo.BindUnmarshaler)(nil)).Elem()
+)
+
+func typeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.String()
+}
+
+// setField sets the field to the values, slices get all values and other types the first one.
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() != reflect.Slice {
+		return setValue(field, values[0])
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+	for idx, value := range values {
+		if err := setValue(slice.Index(idx), value); err != nil {
+			return err
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+func setValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	if ok, err := unmarshalValue(field, value); ok {
+		return err
+	}
+	return setKind(field, value)
+}
+
+// unmarshalValue parses the value into types that define how they are parsed.
+// It returns false if the type of the field is none of them.
+func unmarshalValue(field reflect.Value, value string) (bool, error) {
+	switch target := field.Addr().Interface().(type) {
+	case *time.Time:
+		parsed, err := parseTime(value)
+		if err == nil {
+			*target = parsed
+		}
+		return true, err
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err == nil {
+			*target = parsed
+		}
+		return true, err
+	case echo.BindUnmarshaler:
+		return true, target.UnmarshalParam(value)
+	case encoding.TextUnmarshaler:
+		return true, target.UnmarshalText([]byte(value))
+	}
+	return false, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// setKind parses the value according to the kind of the field. The field is only changed if the value is valid.
+func setKind(field reflect.Value, value string) error {
+	var parsed interface{}
+	var err error
+	switch field.Kind() {
+	case reflect.String:
+		parsed = value
+	case reflect.Bool:
+		parsed, err = strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err = strconv.ParseInt(value, 10, field.Type().Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err = strconv.ParseUint(value, 10, field.Type().Bits())
+	case reflect.Float32, reflect.Float64:
+		parsed, err = strconv.ParseFloat(value, field.Type().Bits())
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedType, field.Type())
+	}
+	if err != nil {
+		return err
+	}
+	field.Set(reflect.ValueOf(parsed).Convert(field.Type()))
+	return nil
+}
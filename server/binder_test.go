@@ -0,0 +1,184 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+type testPagination struct {
+	Limit int `query:"limit" validate:"lte=100"`
+}
+
+type testItem struct {
+	Name string `json:"name"`
+}
+
+type testOrder struct {
+	testPagination
+	ID        int           `param:"id" json:"id"`
+	Tenant    string        `header:"X-Tenant" query:"tenant" json:"tenant"`
+	Since     time.Time     `query:"since"`
+	Wait      time.Duration `query:"wait"`
+	Tags      []string      `query:"tag"`
+	MinTotal  *Amount       `query:"minTotal"`
+	Total     Amount        `json:"total" validate:"gt=0"`
+	Items     []testItem    `json:"items"`
+	Reference *string       `json:"reference"`
+}
+
+func TestBinder(t *testing.T) {
+	t.Run("binds path, query, header and body", func(t *testing.T) {
+		order := &testOrder{}
+		rec := bindTestOrder(t, order, NewWithOptions, "/orders/42?limit=10&tenant=query&since=2022-03-01&wait=1m30s&tag=a&tag=b&minTotal=9.90",
+			`{"id":1,"tenant":"body","total":"19.99","items":[{"name":"book"}]}`, map[string]string{"X-Tenant": "header"})
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, 42, order.ID, "path parameters take precedence over the body")
+		assert.Equal(t, "header", order.Tenant, "headers take precedence over query parameters")
+		assert.Equal(t, 10, order.Limit)
+		assert.Equal(t, time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC), order.Since)
+		assert.Equal(t, 90*time.Second, order.Wait)
+		assert.Equal(t, []string{"a", "b"}, order.Tags)
+		require.NotNil(t, order.MinTotal)
+		assert.Equal(t, "9.90", order.MinTotal.String())
+		assert.Equal(t, "19.99", order.Total.String())
+		assert.Equal(t, []testItem{{Name: "book"}}, order.Items)
+	})
+
+	t.Run("query parameter takes precedence over the body", func(t *testing.T) {
+		order := &testOrder{}
+		rec := bindTestOrder(t, order, NewWithOptions, "/orders/42?tenant=query", `{"tenant":"body","total":1}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "query", order.Tenant)
+	})
+
+	t.Run("fields of embedded structs", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/1?limit=many", `{"total":1}`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request could not be parsed","details":[
+			{"field":"limit","rule":"type","param":"int","message":"limit must be of type int"}
+		]}}`, rec.Body.String())
+	})
+
+	t.Run("reports all errors together", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/abc?limit=200&since=yesterday",
+			`{"total":0,"reference":5}`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request could not be parsed","details":[
+			{"field":"reference","rule":"type","param":"string","message":"reference must be of type string"},
+			{"field":"since","rule":"type","param":"time.Time","message":"since must be of type time.Time"},
+			{"field":"id","rule":"type","param":"int","message":"id must be of type int"},
+			{"field":"limit","rule":"lte","param":"100","message":"limit must be less than or equal to 100"},
+			{"field":"total","rule":"gt","param":"0","message":"total must be greater than 0"}
+		]}}`, rec.Body.String())
+	})
+
+	t.Run("reports all fields with the wrong type", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/1", `{"total":"cheap","items":[{"name":"book"},{"name":1}]}`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request could not be parsed","details":[
+			{"field":"items[1].name","rule":"type","param":"string","message":"items[1].name must be of type string"},
+			{"field":"total","rule":"type","param":"server.Amount","message":"total must be of type server.Amount"}
+		]}}`, rec.Body.String())
+	})
+
+	t.Run("null amount", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/1", `{"total":null}`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request validation failed","details":[
+			{"field":"total","rule":"gt","param":"0","message":"total must be greater than 0"}
+		]}}`, rec.Body.String())
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/1", `{"total":`, nil)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request could not be parsed","details":[]}}`, rec.Body.String())
+	})
+
+	t.Run("unknown fields are ignored by default", func(t *testing.T) {
+		rec := bindTestOrder(t, &testOrder{}, NewWithOptions, "/orders/1", `{"total":1,"color":"red"}`, nil)
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	})
+
+	t.Run("unknown fields are rejected", func(t *testing.T) {
+		newServer := func(obs *observance.Obs, opts ...Option) (*echo.Echo, chan struct{}, error) {
+			return NewWithOptions(obs, append(opts, WithBinder(BinderConfig{DisallowUnknownFields: true}))...)
+		}
+		rec := bindTestOrder(t, &testOrder{}, newServer, "/orders/1",
+			`{"TOTAL":1,"color":"red","items":[{"name":"book"},{"name":"pen","size":3}]}`, nil)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"message":{"message":"request could not be parsed","details":[
+			{"field":"color","rule":"unknown","message":"color is not allowed"},
+			{"field":"items[1].size","rule":"unknown","message":"items[1].size is not allowed"}
+		]}}`, rec.Body.String())
+	})
+
+	t.Run("form body", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+		require.NoError(t, err)
+		payload := &struct {
+			Name string `form:"name" validate:"required"`
+			Page int    `query:"page"`
+		}{}
+		e.POST("/form", func(c echo.Context) error {
+			return c.Bind(payload)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/form?page=2", strings.NewReader("name=John"))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "John", payload.Name)
+		assert.Equal(t, 2, payload.Page)
+	})
+
+	t.Run("unsupported type", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+		require.NoError(t, err)
+		e.GET("/unsupported", func(c echo.Context) error {
+			return c.Bind(&struct {
+				Filter map[string]string `query:"filter"`
+			}{})
+		})
+
+		rec := serve(e, "/unsupported?filter=a", nil)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
+
+// bindTestOrder binds the request to the order via the route /orders/:id of a server created by newServer.
+func bindTestOrder(t *testing.T, order *testOrder, newServer func(*observance.Obs, ...Option) (*echo.Echo, chan struct{}, error),
+	target string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	e, _, err := newServer(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	e.POST("/orders/:id", func(c echo.Context) error {
+		return c.Bind(order)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Environment variables that are read by CORSConfigFromEnv.
+const (
+	EnvCORSAllowOrigins     = "CORS_ALLOW_ORIGINS"
+	EnvCORSAllowMethods     = "CORS_ALLOW_METHODS"
+	EnvCORSAllowHeaders     = "CORS_ALLOW_HEADERS"
+	EnvCORSExposeHeaders    = "CORS_EXPOSE_HEADERS"
+	EnvCORSAllowCredentials = "CORS_ALLOW_CREDENTIALS"
+	EnvCORSMaxAge           = "CORS_MAX_AGE"
+)
+
+// CORSConfig defines which cross-origin requests are allowed, see WithCORSConfig.
+type CORSConfig struct {
+	// AllowOrigins are the origins that may send requests, e.g. "https://app.fastbill.com". "*" allows all origins.
+	// A wildcard as first label allows all subdomains, e.g. "https://*.fastbill.com" allows "https://app.fastbill.com"
+	// and "https://a.b.fastbill.com" but not "https://fastbill.com".
+	AllowOrigins []string
+	// AllowMethods are sent in response to preflight requests. By default the methods of the route are allowed.
+	AllowMethods []string
+	// AllowHeaders are the request headers the clients may send, e.g. "FastBill-RequestId".
+	// By default the headers requested in the preflight request are allowed.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers that can be read by the clients besides the CORS-safelisted ones,
+	// e.g. "ETag" or "RateLimit-Remaining".
+	ExposeHeaders []string
+	// AllowCredentials allows requests with cookies or authorization headers. It cannot be combined with the origin "*".
+	AllowCredentials bool
+	// MaxAge defines how long the clients may cache the results of preflight requests. It is sent in seconds.
+	MaxAge time.Duration
+}
+
+// CORSConfigFromEnv reads the CORS settings from the environment variables, e.g. the ones loaded via
+// envloader.LoadEnvs. Lists are comma separated, CORS_ALLOW_CREDENTIALS is a boolean and CORS_MAX_AGE a duration
+// like "10m". Variables that are not set keep the defaults of CORSConfig.
+func CORSConfigFromEnv() (CORSConfig, error) {
+	cfg := CORSConfig{
+		AllowOrigins:  envList(EnvCORSAllowOrigins),
+		AllowMethods:  envList(EnvCORSAllowMethods),
+		AllowHeaders:  envList(EnvCORSAllowHeaders),
+		ExposeHeaders: envList(EnvCORSExposeHeaders),
+	}
+
+	if value := os.Getenv(EnvCORSAllowCredentials); value != "" {
+		allowCredentials, err := strconv.ParseBool(value)
+		if err != nil {
+			return CORSConfig{}, fmt.Errorf("%s could not be parsed: %w", EnvCORSAllowCredentials, err)
+		}
+		cfg.AllowCredentials = allowCredentials
+	}
+	if value := os.Getenv(EnvCORSMaxAge); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil {
+			return CORSConfig{}, fmt.Errorf("%s could not be parsed: %w", EnvCORSMaxAge, err)
+		}
+		cfg.MaxAge = maxAge
+	}
+	return cfg, nil
+}
+
+// envList splits the comma separated value of the environment variable.
+func envList(name string) []string {
+	return splitList(os.Getenv(name))
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, elem := range strings.Split(value, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			list = append(list, elem)
+		}
+	}
+	return list
+}
+
+// CORS returns a middleware that handles preflight requests and adds the CORS headers to the responses of requests
+// from allowed origins. Usually it is applied via WithCORSConfig, it can also be applied to route groups.
+// An error is returned if the config contains invalid origins or allows credentials for all origins.
+func CORS(config CORSConfig) (echo.MiddlewareFunc, error) {
+	matcher, err := newOriginMatcher(config.AllowOrigins)
+	if err != nil {
+		return nil, err
+	}
+	if matcher.any && config.AllowCredentials {
+		return nil, errors.New("invalid CORS config: credentials cannot be allowed for all origins")
+	}
+
+	echoConfig := middleware.CORSConfig{
+		AllowMethods:     config.AllowMethods,
+		AllowHeaders:     config.AllowHeaders,
+		ExposeHeaders:    config.ExposeHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           int(config.MaxAge.Seconds()),
+	}
+	if matcher.any {
+		echoConfig.AllowOrigins = []string{"*"}
+	} else {
+		echoConfig.AllowOriginFunc = func(origin string) (bool, error) {
+			return matcher.match(origin), nil
+		}
+	}
+	return middleware.CORSWithConfig(echoConfig), nil
+}
+
+// originMatcher checks origins against exact origins and origins with a wildcard subdomain.
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+// wildcardOrigin matches the subdomains of an origin, e.g. "https://*.fastbill.com" is stored
+// with the prefix "https://" and the suffix ".fastbill.com".
+type wildcardOrigin struct {
+	prefix string
+	suffix string
+}
+
+func newOriginMatcher(origins []string) (*originMatcher, error) {
+	m := &originMatcher{exact: map[string]bool{}}
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		scheme, host, ok := strings.Cut(origin, "://")
+		switch {
+		case origin == "*":
+			m.any = true
+		case !ok || scheme == "" || host == "":
+			return nil, fmt.Errorf("invalid CORS config: origin %q needs to consist of scheme and host", origin)
+		case !strings.Contains(host, "*"):
+			m.exact[origin] = true
+		case strings.HasPrefix(host, "*.") && !strings.Contains(host[1:], "*"):
+			m.wildcards = append(m.wildcards, wildcardOrigin{prefix: scheme + "://", suffix: host[1:]})
+		default:
+			return nil, fmt.Errorf("invalid CORS config: origin %q may only contain a wildcard as first label", origin)
+		}
+	}
+	return m, nil
+}
+
+func (m *originMatcher) match(origin string) bool {
+	origin = strings.ToLower(origin)
+	if m.any || m.exact[origin] {
+		return true
+	}
+	for _, wildcard := range m.wildcards {
+		if !strings.HasPrefix(origin, wildcard.prefix) || !strings.HasSuffix(origin, wildcard.suffix) {
+			continue
+		}
+		subdomain := strings.TrimSuffix(strings.TrimPrefix(origin, wildcard.prefix), wildcard.suffix)
+		if isSubdomain(subdomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomain reports whether the value consists of one or more DNS labels, so the wildcard cannot match
+// other parts of the origin like ports or user info.
+func isSubdomain(value string) bool {
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
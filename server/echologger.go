@@ -4,150 +4,294 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/labstack/gommon/log"
 
 	"github.com/fastbill/go-service-toolkit/v4/observance"
 )
 
-// Logger is a wrapper for the logrus fieldlogger that fulfills the echo logger interface
+// prefixField is the log field under which the prefix set via SetPrefix is logged.
+const prefixField = "prefix"
+
+// osExit is replaced in tests.
+var osExit = os.Exit
+
+// Logger is a wrapper for the observance logger that fulfills the echo logger interface.
+// JSON values passed to the *j methods are logged as structured fields.
+// If the JSON contains a string under the key "message" or "msg" it is used as log message.
 type Logger struct {
 	observance.Logger
 }
 
-// Output returns the default output.
+// NewLogger creates a new echo logger that writes to the given observance logger.
+// Only loggers created via NewLogger support SetPrefix.
+func NewLogger(logger observance.Logger) Logger {
+	return Logger{Logger: &prefixLogger{Logger: logger}}
+}
+
+// prefixLogger holds the prefix set via SetPrefix. As it is referenced by a pointer, copies of the Logger
+// share the prefix.
+type prefixLogger struct {
+	observance.Logger
+
+	mu     sync.RWMutex
+	prefix string
+}
+
+// outputGetter is implemented by observance loggers that expose their writer, e.g. observance.LogrusLogger.
+type outputGetter interface {
+	Output() io.Writer
+}
+
+// Output returns the writer of the underlying logger.
+// If the logger does not expose its writer, Stdout is returned.
 func (l Logger) Output() io.Writer {
+	if logger, ok := l.base().(outputGetter); ok {
+		return logger.Output()
+	}
 	return os.Stdout
 }
 
-// Prefix is NOT IMPLEMENTED, it is only present to fulfill the echo logger interface
+// Prefix returns the prefix that was set via SetPrefix.
 func (l Logger) Prefix() string {
-	l.Error("trying to use unimplemented log method Prefix")
-	return ""
+	prefixed, ok := l.Logger.(*prefixLogger)
+	if !ok {
+		return ""
+	}
+	prefixed.mu.RLock()
+	defer prefixed.mu.RUnlock()
+	return prefixed.prefix
 }
 
-// SetPrefix is NOT IMPLEMENTED, it is only present to fulfill the echo logger interface
+// SetPrefix sets a prefix that is added as field "prefix" to all log entries.
 func (l Logger) SetPrefix(p string) {
-	l.Error("trying to use unimplemented log method SetPrefix")
+	prefixed, ok := l.Logger.(*prefixLogger)
+	if !ok {
+		l.Error("the prefix can only be set for loggers created via NewLogger")
+		return
+	}
+	prefixed.mu.Lock()
+	defer prefixed.mu.Unlock()
+	prefixed.prefix = p
 }
 
-// Level is NOT IMPLEMENTED, it is only present to fulfill the echo logger interface
+// Level returns the level of the underlying logger mapped to the echo log levels.
+// Trace is mapped to DEBUG, fatal and panic are mapped to ERROR.
 func (l Logger) Level() log.Lvl {
-	l.Error("trying to use unimplemented log method Level")
-	return 0
+	return toEchoLevel(l.Logger.Level())
 }
 
-// SetLevel is NOT IMPLEMENTED, it is only present to fulfill the echo logger interface
+// SetLevel changes the level of the underlying logger.
+// OFF is mapped to the highest level of the underlying logger ("panic").
 func (l Logger) SetLevel(v log.Lvl) {
-	l.Error("trying to use unimplemented log method SetLevel")
-}
+	levelSetter, ok := l.base().(observance.LevelSetter)
+	if !ok {
+		l.Error(fmt.Sprintf("logger of type %T does not support changing the log level", l.base()))
+		return
+	}
 
-// SetHeader is NOT IMPLEMENTED, it is only present to fulfill the echo logger interface
-func (l Logger) SetHeader(h string) {
-	l.Error("trying to use unimplemented log method SetHeader")
+	if err := levelSetter.SetLevel(fromEchoLevel(v)); err != nil {
+		l.Error(err)
+	}
 }
 
-// Panic implements echo.Logger#Panic.
+// SetHeader has no effect. The format of the log entries is defined by the formatter of the underlying logger.
+func (l Logger) SetHeader(h string) {}
+
+// Panic implements echo.Logger#Panic. It logs with level error and panics afterwards.
 func (l Logger) Panic(i ...interface{}) {
-	l.Error(fmt.Sprintf("%v", i))
+	msg := fmt.Sprint(i...)
+	l.logger().Error(msg)
+	panic(msg)
 }
 
-// Panicf implements echo.Logger#Panicf.
+// Panicf implements echo.Logger#Panicf. It logs with level error and panics afterwards.
 func (l Logger) Panicf(format string, args ...interface{}) {
-	l.Error(fmt.Sprintf(format, args...))
+	msg := fmt.Sprintf(format, args...)
+	l.logger().Error(msg)
+	panic(msg)
 }
 
-// Panicj implements echo.Logger#Panicj.
+// Panicj implements echo.Logger#Panicj. It logs with level error and panics afterwards.
 func (l Logger) Panicj(j log.JSON) {
-	l.Error(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Error(msg)
+	panic(msg)
 }
 
-// Fatal implements echo.Logger#Fatal.
+// Fatal implements echo.Logger#Fatal. It logs with level error and exits the program afterwards.
 func (l Logger) Fatal(i ...interface{}) {
-	l.Error(fmt.Sprintf("%v", i))
+	l.logger().Error(fmt.Sprint(i...))
+	l.exit()
 }
 
-// Fatalf implements echo.Logger#Fatalf.
+// Fatalf implements echo.Logger#Fatalf. It logs with level error and exits the program afterwards.
 func (l Logger) Fatalf(format string, args ...interface{}) {
-	l.Error(fmt.Sprintf(format, args...))
+	l.logger().Error(fmt.Sprintf(format, args...))
+	l.exit()
 }
 
-// Fatalj implements echo.Logger#Fatalj.
+// Fatalj implements echo.Logger#Fatalj. It logs with level error and exits the program afterwards.
 func (l Logger) Fatalj(j log.JSON) {
-	l.Error(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Error(msg)
+	l.exit()
+}
+
+// exit waits until the buffered log entries were sent, e.g. to Sentry, and exits the program.
+func (l Logger) exit() {
+	if flusher, ok := l.base().(observance.Flusher); ok {
+		flusher.Flush()
+	}
+	osExit(1)
 }
 
 // Error implements echo.Logger#Error.
 func (l Logger) Error(i ...interface{}) {
-	l.Logger.Error(fmt.Sprintf("%v", i))
+	l.logger().Error(fmt.Sprint(i...))
 }
 
 // Errorf implements echo.Logger#Errorf.
 func (l Logger) Errorf(format string, args ...interface{}) {
-	l.Error(fmt.Sprintf(format, args...))
+	l.logger().Error(fmt.Sprintf(format, args...))
 }
 
 // Errorj implements echo.Logger#Errorj.
 func (l Logger) Errorj(j log.JSON) {
-	l.Error(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Error(msg)
 }
 
 // Warn implements echo.Logger#Warn.
 func (l Logger) Warn(i ...interface{}) {
-	l.Logger.Warn(fmt.Sprintf("%v", i))
+	l.logger().Warn(fmt.Sprint(i...))
 }
 
 // Warnf implements echo.Logger#Warnf.
 func (l Logger) Warnf(format string, args ...interface{}) {
-	l.Warn(fmt.Sprintf(format, args...))
+	l.logger().Warn(fmt.Sprintf(format, args...))
 }
 
 // Warnj implements echo.Logger#Warnj.
 func (l Logger) Warnj(j log.JSON) {
-	l.Warn(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Warn(msg)
 }
 
 // Info implements echo.Logger#Info.
 func (l Logger) Info(i ...interface{}) {
-	l.Logger.Info(fmt.Sprintf("%v", i))
+	l.logger().Info(fmt.Sprint(i...))
 }
 
 // Infof implements echo.Logger#Infof.
 func (l Logger) Infof(format string, args ...interface{}) {
-	l.Info(fmt.Sprintf(format, args...))
+	l.logger().Info(fmt.Sprintf(format, args...))
 }
 
 // Infoj implements echo.Logger#Infoj.
 func (l Logger) Infoj(j log.JSON) {
-	l.Info(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Info(msg)
 }
 
 // Debug implements echo.Logger#Debug.
 func (l Logger) Debug(i ...interface{}) {
-	l.Logger.Debug(fmt.Sprintf("%v", i))
+	l.logger().Debug(fmt.Sprint(i...))
 }
 
 // Debugf implements echo.Logger#Debugf.
 func (l Logger) Debugf(format string, args ...interface{}) {
-	l.Debug(fmt.Sprintf(format, args...))
+	l.logger().Debug(fmt.Sprintf(format, args...))
 }
 
 // Debugj implements echo.Logger#Debugj.
 func (l Logger) Debugj(j log.JSON) {
-	l.Debug(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Debug(msg)
 }
 
-// Print implements echo.Logger#Print.
+// Print implements echo.Logger#Print. It logs with level info.
 func (l Logger) Print(i ...interface{}) {
-	l.Info(fmt.Sprintf("%v", i))
+	l.logger().Info(fmt.Sprint(i...))
 }
 
-// Printf implements echo.Logger#Printf.
+// Printf implements echo.Logger#Printf. It logs with level info.
 func (l Logger) Printf(format string, args ...interface{}) {
-	l.Info(fmt.Sprintf(format, args...))
+	l.logger().Info(fmt.Sprintf(format, args...))
 }
 
-// Printj implements echo.Logger#Printj.
+// Printj implements echo.Logger#Printj. It logs with level info.
 func (l Logger) Printj(j log.JSON) {
-	l.Info(fmt.Sprintf("%+v", j))
+	logger, msg := l.loggerWithJSON(j)
+	logger.Info(msg)
+}
+
+// base returns the observance logger that was passed to NewLogger.
+func (l Logger) base() observance.Logger {
+	if prefixed, ok := l.Logger.(*prefixLogger); ok {
+		return prefixed.Logger
+	}
+	return l.Logger
+}
+
+// logger returns the underlying logger including the prefix field if a prefix was set.
+func (l Logger) logger() observance.Logger {
+	prefix := l.Prefix()
+	if prefix == "" {
+		return l.base()
+	}
+	return l.base().WithField(prefixField, prefix)
+}
+
+// loggerWithJSON adds the JSON values as fields to the logger and extracts the message.
+func (l Logger) loggerWithJSON(j log.JSON) (observance.Logger, string) {
+	msg, msgKey := "", ""
+	for _, key := range []string{"message", "msg"} {
+		if str, ok := j[key].(string); ok {
+			msg, msgKey = str, key
+			break
+		}
+	}
+
+	fields := make(observance.Fields, len(j))
+	for key, value := range j {
+		if key != msgKey {
+			fields[key] = value
+		}
+	}
+
+	logger := l.logger()
+	if len(fields) > 0 {
+		logger = logger.WithFields(fields)
+	}
+	return logger, msg
+}
+
+func toEchoLevel(level string) log.Lvl {
+	switch level {
+	case "trace", "debug":
+		return log.DEBUG
+	case "info":
+		return log.INFO
+	case "warning":
+		return log.WARN
+	default:
+		return log.ERROR
+	}
+}
+
+func fromEchoLevel(level log.Lvl) string {
+	switch level {
+	case log.DEBUG:
+		return "debug"
+	case log.INFO:
+		return "info"
+	case log.WARN:
+		return "warning"
+	case log.ERROR:
+		return "error"
+	default:
+		return "panic"
+	}
 }
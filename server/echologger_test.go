@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestLoggerLevel(t *testing.T) {
+	testLogger := observance.NewTestLogger()
+	logger := NewLogger(testLogger)
+
+	cases := []struct {
+		echoLevel     log.Lvl
+		expectedLevel string
+		mappedBack    log.Lvl
+	}{
+		{log.DEBUG, "debug", log.DEBUG},
+		{log.INFO, "info", log.INFO},
+		{log.WARN, "warning", log.WARN},
+		{log.ERROR, "error", log.ERROR},
+		{log.OFF, "panic", log.ERROR},
+	}
+
+	for _, test := range cases {
+		t.Run(test.expectedLevel, func(t *testing.T) {
+			logger.SetLevel(test.echoLevel)
+			assert.Equal(t, test.expectedLevel, testLogger.Level())
+			assert.Equal(t, test.mappedBack, logger.Level())
+		})
+	}
+
+	t.Run("trace", func(t *testing.T) {
+		assert.NoError(t, testLogger.(observance.LevelSetter).SetLevel("trace"))
+		assert.Equal(t, log.DEBUG, logger.Level())
+	})
+}
+
+func TestLogger(t *testing.T) {
+	assert.Implements(t, (*echo.Logger)(nil), NewLogger(observance.NewTestLogger()))
+
+	t.Run("messages", func(t *testing.T) {
+		testLogger := observance.NewTestLogger()
+		logger := NewLogger(testLogger)
+
+		logger.Error("some", "message")
+		assert.Equal(t, observance.TestLogEntry{Level: "error", Message: "somemessage", Data: map[string]interface{}{}}, testLogger.LastEntry())
+
+		logger.Warnf("value %d", 1)
+		assert.Equal(t, observance.TestLogEntry{Level: "warning", Message: "value 1", Data: map[string]interface{}{}}, testLogger.LastEntry())
+
+		logger.Print(errors.New("testError"))
+		assert.Equal(t, observance.TestLogEntry{Level: "info", Message: "testError", Data: map[string]interface{}{}}, testLogger.LastEntry())
+	})
+
+	t.Run("JSON as fields", func(t *testing.T) {
+		testLogger := observance.NewTestLogger()
+		logger := NewLogger(testLogger)
+
+		logger.Infoj(log.JSON{"message": "testMessage", "status": 200, "msg": "other"})
+		assert.Equal(t, observance.TestLogEntry{
+			Level:   "info",
+			Message: "testMessage",
+			Data:    map[string]interface{}{"status": 200, "msg": "other"},
+		}, testLogger.LastEntry())
+
+		logger.Errorj(log.JSON{"status": 500})
+		assert.Equal(t, observance.TestLogEntry{
+			Level:   "error",
+			Message: "",
+			Data:    map[string]interface{}{"status": 500},
+		}, testLogger.LastEntry())
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		testLogger := observance.NewTestLogger()
+		logger := NewLogger(testLogger)
+		assert.Equal(t, "", logger.Prefix())
+
+		logger.SetPrefix("echo")
+		assert.Equal(t, "echo", logger.Prefix())
+
+		logger.Debug("testMessage")
+		assert.Equal(t, map[string]interface{}{"prefix": "echo"}, testLogger.LastEntry().Data)
+
+		logger.Debugj(log.JSON{"msg": "testMessage", "id": 1})
+		assert.Equal(t, map[string]interface{}{"prefix": "echo", "id": 1}, testLogger.LastEntry().Data)
+	})
+
+	t.Run("prefix is shared by copies", func(t *testing.T) {
+		logger := NewLogger(observance.NewTestLogger())
+		loggerCopy := logger
+		logger.SetPrefix("echo")
+		assert.Equal(t, "echo", loggerCopy.Prefix())
+	})
+
+	t.Run("literal", func(t *testing.T) {
+		testLogger := observance.NewTestLogger()
+		var logger echo.Logger = Logger{testLogger}
+
+		logger.Info("testMessage")
+		assert.Equal(t, "testMessage", testLogger.LastEntry().Message)
+
+		logger.SetPrefix("echo")
+		assert.Equal(t, "", logger.Prefix())
+		assert.Equal(t, "the prefix can only be set for loggers created via NewLogger", testLogger.LastEntry().Message)
+
+		logger.SetLevel(log.WARN)
+		assert.Equal(t, log.WARN, logger.Level())
+	})
+
+	t.Run("fatal", func(t *testing.T) {
+		exitCode := 0
+		osExit = func(code int) { exitCode = code }
+		defer func() { osExit = os.Exit }()
+		testLogger := &flushingLogger{TestLogger: observance.NewTestLogger()}
+		logger := NewLogger(testLogger)
+
+		logger.Fatalf("test %d", 1)
+		assert.Equal(t, 1, exitCode)
+		assert.Equal(t, "test 1", testLogger.LastEntry().Message)
+		assert.True(t, testLogger.flushed, "the entries need to be sent before exiting")
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		testLogger := observance.NewTestLogger()
+		logger := NewLogger(testLogger)
+
+		assert.PanicsWithValue(t, "test 1", func() { logger.Panicf("test %d", 1) })
+		assert.Equal(t, "error", testLogger.LastEntry().Level)
+		assert.Equal(t, "test 1", testLogger.LastEntry().Message)
+	})
+
+	t.Run("output", func(t *testing.T) {
+		obs, err := observance.NewObs(observance.Config{LogLevel: "info"})
+		require.NoError(t, err)
+		capture := &bytes.Buffer{}
+		logger := NewLogger(obs.Logger)
+
+		logger.SetOutput(capture)
+		assert.Equal(t, capture, logger.Output())
+
+		logger.Info("testMessage")
+		assert.Contains(t, capture.String(), `"msg":"testMessage"`)
+	})
+}
+
+// flushingLogger records whether it was flushed.
+type flushingLogger struct {
+	observance.TestLogger
+	flushed bool
+}
+
+func (l *flushingLogger) Flush() {
+	l.flushed = true
+}
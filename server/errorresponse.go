@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// MIMEApplicationProblemJSON is the content type of RFC 7807 problem details.
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// ErrorResponse contains all information about an error that an ErrorEncoder can include in the response.
+type ErrorResponse struct {
+	// Status is the HTTP status code of the response.
+	Status int
+	// Message is the message of the HTTPError, usually a string.
+	Message interface{}
+	// Code is the machine readable error code of a CodedError (optional).
+	Code string
+	// RequestID is the ID of the request if it was found in the configured header (optional).
+	RequestID string
+	// Validation contains the field errors if binding or validating the request failed (optional).
+	Validation *ValidationError
+	// Request is the request that caused the error.
+	Request *http.Request
+}
+
+// ErrorEncoder writes error responses in a specific format.
+type ErrorEncoder interface {
+	// ContentType is used to select the encoder based on the Accept header of the request.
+	ContentType() string
+	// Encode writes the headers, the status and the body of the response.
+	Encode(w http.ResponseWriter, response ErrorResponse) error
+}
+
+// ErrorResponseConfig configures the format of the error responses sent by the HTTPErrorHandler.
+type ErrorResponseConfig struct {
+	// Encoders are the available response formats. The format is chosen via the Accept header of the request,
+	// if no encoder matches, the first one is used. The default is LegacyErrorEncoder.
+	Encoders []ErrorEncoder
+	// RequestIDHeader is the request header that contains the request ID. If it is not set in the request,
+	// the response header is checked (e.g. set by the request ID middleware of Echo). The default is X-Request-ID.
+	RequestIDHeader string
+}
+
+func (cfg ErrorResponseConfig) withDefaults() ErrorResponseConfig {
+	if len(cfg.Encoders) == 0 {
+		cfg.Encoders = []ErrorEncoder{LegacyErrorEncoder{}}
+	}
+	if cfg.RequestIDHeader == "" {
+		cfg.RequestIDHeader = echo.HeaderXRequestID
+	}
+	return cfg
+}
+
+// selectEncoder returns the first encoder matching the media ranges of the Accept header, e.g. "application/*".
+func (cfg ErrorResponseConfig) selectEncoder(accept string) ErrorEncoder {
+	for _, mediaRange := range parseQualityValues(accept) {
+		for _, encoder := range cfg.Encoders {
+			if matchesMediaRange(encoder.ContentType(), mediaRange) {
+				return encoder
+			}
+		}
+	}
+	return cfg.Encoders[0]
+}
+
+func (cfg ErrorResponseConfig) requestID(c echo.Context) string {
+	if requestID := c.Request().Header.Get(cfg.RequestIDHeader); requestID != "" {
+		return requestID
+	}
+	return c.Response().Header().Get(cfg.RequestIDHeader)
+}
+
+func matchesMediaRange(contentType, mediaRange string) bool {
+	if mediaRange == "*/*" || mediaRange == strings.ToLower(contentType) {
+		return true
+	}
+	if strings.HasSuffix(mediaRange, "/*") {
+		return strings.HasPrefix(strings.ToLower(contentType), strings.TrimSuffix(mediaRange, "*"))
+	}
+	return false
+}
+
+// LegacyErrorEncoder writes the format of httperrors.HTTPError, e.g. {"message": "not allowed"}.
+// The error code of a CodedError is added as "code".
+type LegacyErrorEncoder struct{}
+
+// ContentType returns application/json.
+func (LegacyErrorEncoder) ContentType() string {
+	return echo.MIMEApplicationJSON
+}
+
+// Encode writes the response.
+func (LegacyErrorEncoder) Encode(w http.ResponseWriter, response ErrorResponse) error {
+	httpError := httperrors.New(response.Status, response.Message)
+	if err, ok := httpError.Message.(error); ok {
+		httpError.Message = err.Error()
+	}
+
+	w.Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	w.WriteHeader(response.Status)
+	return json.NewEncoder(w).Encode(struct {
+		Message interface{} `json:"message"`
+		Code    string      `json:"code,omitempty"`
+	}{httpError.Message, response.Code})
+}
+
+// ProblemErrorEncoder writes RFC 7807 problem details, e.g.
+//
+//	{"type": "about:blank", "title": "Not Found", "status": 404, "detail": "invoice not found", "instance": "/invoices/1", "requestId": "abc"}
+//
+// The extension members "requestId", "code" and "errors" (the field errors of a ValidationError) are only added if present.
+// For errors with status 5xx the detail (and any other message) is omitted, so internal error messages are not exposed.
+type ProblemErrorEncoder struct {
+	// TypeBaseURL is used to build the problem type for errors with an error code, e.g. "https://example.com/problems/"
+	// results in "https://example.com/problems/invoice_locked". Without it or without a code the type is "about:blank".
+	TypeBaseURL string
+}
+
+// Problem is the body written by the ProblemErrorEncoder.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// Message contains the message of the HTTPError if it cannot be represented as detail, e.g. a map.
+	Message interface{} `json:"message,omitempty"`
+}
+
+// ContentType returns application/problem+json.
+func (ProblemErrorEncoder) ContentType() string {
+	return MIMEApplicationProblemJSON
+}
+
+// Encode writes the response.
+func (e ProblemErrorEncoder) Encode(w http.ResponseWriter, response ErrorResponse) error {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     statusText(response.Status),
+		Status:    response.Status,
+		RequestID: response.RequestID,
+		Code:      response.Code,
+	}
+	if e.TypeBaseURL != "" && response.Code != "" {
+		problem.Type = e.TypeBaseURL + response.Code
+	}
+	if response.Request != nil {
+		problem.Instance = response.Request.URL.Path
+	}
+
+	// The messages of internal errors are only logged, they are not sent to the client. Also messages given as string
+	// can contain internal details, e.g. the text of a CodedError.
+	if response.Status < http.StatusInternalServerError {
+		switch message := response.Message.(type) {
+		case string:
+			problem.Detail = message
+		case *ValidationError:
+			problem.Detail = message.Message
+			problem.Errors = message.Details
+		case error:
+			problem.Detail = message.Error()
+		default:
+			problem.Message = message
+		}
+	}
+
+	w.Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	w.WriteHeader(response.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
+
+func statusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return fmt.Sprintf("Status %d", status)
+}
+
+type errorEncoderFunc struct {
+	contentType string
+	encode      func(w http.ResponseWriter, response ErrorResponse) error
+}
+
+func (e *errorEncoderFunc) ContentType() string {
+	return e.contentType
+}
+
+func (e *errorEncoderFunc) Encode(w http.ResponseWriter, response ErrorResponse) error {
+	return e.encode(w, response)
+}
+
+// NewErrorEncoder creates an ErrorEncoder for a custom format with the given content type.
+func NewErrorEncoder(contentType string, encode func(w http.ResponseWriter, response ErrorResponse) error) ErrorEncoder {
+	return &errorEncoderFunc{contentType: contentType, encode: encode}
+}
@@ -0,0 +1,170 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestErrorResponses(t *testing.T) {
+	customEncoder := NewErrorEncoder("text/plain", func(w http.ResponseWriter, response ErrorResponse) error {
+		w.Header().Set(echo.HeaderContentType, "text/plain")
+		w.WriteHeader(response.Status)
+		_, err := fmt.Fprintf(w, "%d %v", response.Status, response.Message)
+		return err
+	})
+
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+	e, _, err := NewWithOptions(obs, WithErrorResponses(ErrorResponseConfig{
+		Encoders:        []ErrorEncoder{LegacyErrorEncoder{}, ProblemErrorEncoder{TypeBaseURL: "https://example.com/problems/"}, customEncoder},
+		RequestIDHeader: "FastBill-RequestId",
+	}))
+	require.NoError(t, err)
+	e.GET("/forbidden", func(c echo.Context) error {
+		return httperrors.New(http.StatusForbidden, "not allowed")
+	})
+	e.GET("/coded", func(c echo.Context) error {
+		return &testDomainError{status: http.StatusConflict}
+	})
+	e.GET("/coded-internal", func(c echo.Context) error {
+		return &testDomainError{status: http.StatusBadGateway}
+	})
+	e.GET("/unavailable", func(c echo.Context) error {
+		return httperrors.New(http.StatusServiceUnavailable, map[string]string{"database": "connection refused"})
+	})
+	e.GET("/map", func(c echo.Context) error {
+		return httperrors.New(http.StatusBadRequest, map[string]int{"limit": 10})
+	})
+	e.GET("/error", func(c echo.Context) error {
+		return errors.New("testError")
+	})
+	e.POST("/validate", func(c echo.Context) error {
+		return c.Bind(&testUser{})
+	})
+	e.GET("/html", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextHTMLCharsetUTF8)
+		return httperrors.New(http.StatusNotFound, "page not found")
+	})
+
+	cases := []struct {
+		name                string
+		method              string
+		route               string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			"legacy by default",
+			http.MethodGet, "/forbidden", "",
+			echo.MIMEApplicationJSON,
+			`{"message":"not allowed"}`,
+		},
+		{
+			"legacy for JSON",
+			http.MethodGet, "/forbidden", "application/json",
+			echo.MIMEApplicationJSON,
+			`{"message":"not allowed"}`,
+		},
+		{
+			"problem",
+			http.MethodGet, "/forbidden", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Forbidden","status":403,"detail":"not allowed","instance":"/forbidden","requestId":"testRequestID"}`,
+		},
+		{
+			"problem by quality",
+			http.MethodGet, "/coded", "application/json;q=0.5, application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"https://example.com/problems/invoice_locked","title":"Conflict","status":409,"detail":"invoice is locked","instance":"/coded","requestId":"testRequestID","code":"invoice_locked"}`,
+		},
+		{
+			"problem without detail for internal coded error",
+			http.MethodGet, "/coded-internal", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"https://example.com/problems/invoice_locked","title":"Bad Gateway","status":502,"instance":"/coded-internal","requestId":"testRequestID","code":"invoice_locked"}`,
+		},
+		{
+			"problem without message for internal error",
+			http.MethodGet, "/unavailable", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Service Unavailable","status":503,"instance":"/unavailable","requestId":"testRequestID"}`,
+		},
+		{
+			"problem with message that is no string",
+			http.MethodGet, "/map", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Bad Request","status":400,"instance":"/map","requestId":"testRequestID","message":{"limit":10}}`,
+		},
+		{
+			"problem for standard error",
+			http.MethodGet, "/error", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/error","requestId":"testRequestID"}`,
+		},
+		{
+			"problem with validation errors",
+			http.MethodPost, "/validate", "application/problem+json",
+			MIMEApplicationProblemJSON,
+			`{"type":"about:blank","title":"Bad Request","status":400,"detail":"request validation failed","instance":"/validate","requestId":"testRequestID","errors":[{"field":"name","rule":"required","message":"name is required"}]}`,
+		},
+		{
+			"legacy replaces the content type",
+			http.MethodGet, "/html", "",
+			echo.MIMEApplicationJSON,
+			`{"message":"page not found"}`,
+		},
+		{
+			"custom encoder",
+			http.MethodGet, "/forbidden", "text/*",
+			"text/plain",
+			`403 not allowed`,
+		},
+		{
+			"fallback to first encoder",
+			http.MethodGet, "/forbidden", "application/xml",
+			echo.MIMEApplicationJSON,
+			`{"message":"not allowed"}`,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.route, strings.NewReader(`{"age":18,"address":{"zip":"12345"}}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("FastBill-RequestId", "testRequestID")
+			if test.accept != "" {
+				req.Header.Set(echo.HeaderAccept, test.accept)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, []string{test.expectedContentType}, rec.Header().Values(echo.HeaderContentType))
+			assert.Equal(t, test.expectedBody, strings.TrimSpace(rec.Body.String()))
+			assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+		})
+	}
+}
+
+func TestRequestIDFromResponse(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+	handler := HTTPErrorHandlerWithConfig(obs, ErrorResponseConfig{Encoders: []ErrorEncoder{ProblemErrorEncoder{}}})
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(echo.HeaderXRequestID, "generatedID")
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	handler(&testDomainError{status: StatusClientClosedRequest}, c)
+
+	assert.Equal(t, `{"type":"about:blank","title":"Client Closed Request","status":499,"detail":"invoice is locked","instance":"/","requestId":"generatedID","code":"invoice_locked"}`, strings.TrimSpace(rec.Body.String()))
+	assert.Empty(t, rec.Header().Get(echo.HeaderVary))
+}
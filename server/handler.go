@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+const obsKey = "toolkit.obs"
+
+// HandlerOption configures the handlers created by Handle.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	status int
+}
+
+// WithStatus sets the status code of the responses of successful requests, the default is 200.
+// For http.StatusNoContent the response is sent without a body.
+func WithStatus(status int) HandlerOption {
+	return func(cfg *handlerConfig) {
+		cfg.status = status
+	}
+}
+
+// Handle adapts a typed function to an echo handler, so the handlers do not need to repeat binding, validation and
+// encoding. Req needs to be a struct, it is bound via c.Bind, so the body, path and query parameters and headers
+// are bound and validated by the Binder of the server. The context passed to the function is the context of
+// the request, for servers created via NewWithOptions it carries the request specific Obs
+// (see observance.ObsFromContext). The returned value is sent as JSON. Errors, including binding and validation
+// errors, are returned to echo, so the response is sent by the HTTPErrorHandler.
+func Handle[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error), opts ...HandlerOption) echo.HandlerFunc {
+	cfg := handlerConfig{status: http.StatusOK}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(c echo.Context) error {
+		var req Req
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+
+		resp, err := fn(requestContext(c), req)
+		if err != nil {
+			return err
+		}
+		if cfg.status == http.StatusNoContent {
+			return c.NoContent(cfg.status)
+		}
+		return c.JSON(cfg.status, resp)
+	}
+}
+
+// requestContext adds the request specific Obs to the context of the request. It is created after the middleware
+// was run, so log fields added by the middleware (e.g. the subject of an authenticated user) are included.
+func requestContext(c echo.Context) context.Context {
+	ctx := c.Request().Context()
+	obs, ok := c.Get(obsKey).(*observance.Obs)
+	if !ok {
+		return ctx
+	}
+
+	ctx = observance.ContextWithObs(ctx, obs.CopyWithRequest(c.Request()))
+	c.SetRequest(c.Request().WithContext(ctx))
+	return ctx
+}
+
+// provideObs makes the Obs of the server available to the handlers created by Handle.
+func provideObs(obs *observance.Obs) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(obsKey, obs)
+			return next(c)
+		}
+	}
+}
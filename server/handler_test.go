@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+type testCreateInvoice struct {
+	CustomerID int    `param:"customerId"`
+	Title      string `json:"title" validate:"required"`
+}
+
+type testInvoice struct {
+	ID         int    `json:"id"`
+	CustomerID int    `json:"customerId"`
+	Title      string `json:"title"`
+}
+
+func TestHandle(t *testing.T) {
+	logger := observance.NewTestLogger()
+	e, _, err := NewWithOptions(&observance.Obs{Logger: logger})
+	require.NoError(t, err)
+
+	e.POST("/customers/:customerId/invoices", Handle(func(ctx context.Context, req testCreateInvoice) (testInvoice, error) {
+		if req.Title == "locked" {
+			return testInvoice{}, &testDomainError{status: http.StatusConflict}
+		}
+		observance.ObsFromContext(ctx).Logger.Info("creating invoice")
+		return testInvoice{ID: 1, CustomerID: req.CustomerID, Title: req.Title}, nil
+	}, WithStatus(http.StatusCreated)))
+	e.DELETE("/invoices/:id", Handle(func(ctx context.Context, req struct {
+		ID int `param:"id"`
+	}) (struct{}, error) {
+		return struct{}{}, nil
+	}, WithStatus(http.StatusNoContent)))
+
+	cases := []struct {
+		name         string
+		method       string
+		target       string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "success",
+			method:       http.MethodPost,
+			target:       "/customers/42/invoices",
+			body:         `{"title":"Rent"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"id":1,"customerId":42,"title":"Rent"}`,
+		},
+		{
+			name:         "validation error",
+			method:       http.MethodPost,
+			target:       "/customers/42/invoices",
+			body:         `{}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `{"message":{"message":"request validation failed","details":[
+				{"field":"title","rule":"required","message":"title is required"}
+			]}}`,
+		},
+		{
+			name:         "error of the handler",
+			method:       http.MethodPost,
+			target:       "/customers/42/invoices",
+			body:         `{"title":"locked"}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: `{"message":"invoice is locked","code":"invoice_locked"}`,
+		},
+		{
+			name:         "no content",
+			method:       http.MethodDelete,
+			target:       "/invoices/1",
+			expectedCode: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			if test.expectedBody == "" {
+				assert.Empty(t, rec.Body.String())
+				return
+			}
+			assert.JSONEq(t, test.expectedBody, rec.Body.String())
+		})
+	}
+
+	t.Run("request specific Obs", func(t *testing.T) {
+		logger.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/customers/42/invoices", strings.NewReader(`{"title":"Rent"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		entry := logger.LastEntry()
+		assert.Equal(t, "creating invoice", entry.Message)
+		assert.Equal(t, "/customers/42/invoices", entry.Data["url"])
+		assert.Equal(t, http.MethodPost, entry.Data["method"])
+	})
+}
+
+func TestHandleWithoutObs(t *testing.T) {
+	e := echo.New()
+	e.Validator = NewValidator()
+	e.GET("/", Handle(func(ctx context.Context, req struct{}) (string, error) {
+		assert.Nil(t, observance.ObsFromContext(ctx))
+		return "ok", nil
+	}))
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `"ok"`, rec.Body.String())
+}
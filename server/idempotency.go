@@ -0,0 +1,258 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+)
+
+const (
+	// HeaderIdempotencyKey contains the key the client generated for the request, e.g. a UUID.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set to "true" if the response was replayed from the cache.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL     = 24 * time.Hour
+	defaultIdempotencyLockTTL = time.Minute
+)
+
+// Errors returned by the Idempotency middleware.
+var (
+	ErrIdempotencyKeyMissing  = httperrors.New(http.StatusBadRequest, "the Idempotency-Key header is required")
+	ErrIdempotencyKeyInUse    = httperrors.New(http.StatusConflict, "a request with the same Idempotency-Key is still in progress")
+	ErrIdempotencyKeyMismatch = httperrors.New(http.StatusUnprocessableEntity, "the Idempotency-Key was already used for a different request")
+)
+
+// IdempotencyConfig configures the Idempotency middleware.
+type IdempotencyConfig struct {
+	// Cache stores the responses and the locks. It needs to implement cache.Locker, e.g. cache.RedisClient.
+	// The prefix of the cache client is applied to all keys.
+	Cache cache.Cache
+	// TTL defines how long responses are stored. The default is 24 hours.
+	TTL time.Duration
+	// LockTTL defines after which time a request is not considered in progress anymore, e.g. if the instance
+	// crashed while handling it. It should be longer than the handler timeout. The default is one minute.
+	LockTTL time.Duration
+	// Methods are the HTTP methods the middleware applies to. The default is POST and PATCH.
+	Methods []string
+	// Required rejects requests without the Idempotency-Key header with a 400 response.
+	Required bool
+	// Scope separates the keys of different clients, e.g. KeyByContext for the subject of an authenticated user.
+	// By default the keys of all clients share one namespace.
+	Scope func(c echo.Context) string
+}
+
+// storedResponse is the response that is replayed for retried requests.
+type storedResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+// Idempotency returns a middleware that makes retried requests with the same Idempotency-Key header safe.
+// The first response (status, headers and body) is stored in the cache and sent again for all retries without
+// calling the handler. Retries while the first request is still in progress receive a 409 response. If the key is
+// reused for a request with a different method, path or body, a 422 response is sent.
+// Responses with status 5xx and errors returned by the handler are not stored, so the request can be retried.
+// It panics if the cache does not implement cache.Locker.
+func Idempotency(config IdempotencyConfig) echo.MiddlewareFunc {
+	config = config.withDefaults()
+	locker, ok := config.Cache.(cache.Locker)
+	if !ok {
+		panic(fmt.Sprintf("idempotency: cache of type %T needs to implement cache.Locker", config.Cache))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !config.appliesTo(c.Request().Method) {
+				return next(c)
+			}
+			idempotencyKey := c.Request().Header.Get(HeaderIdempotencyKey)
+			if idempotencyKey == "" {
+				if config.Required {
+					return ErrIdempotencyKeyMissing
+				}
+				return next(c)
+			}
+
+			fingerprint, err := requestFingerprint(c)
+			if err != nil {
+				return err
+			}
+
+			key := "idempotency:" + idempotencyKey
+			if config.Scope != nil {
+				key = "idempotency:" + config.Scope(c) + ":" + idempotencyKey
+			}
+
+			replayed, err := replayResponse(c, config.Cache, key, fingerprint)
+			if replayed || err != nil {
+				return err
+			}
+
+			return handleIdempotent(c, next, config, locker, key, fingerprint)
+		}
+	}
+}
+
+func (config IdempotencyConfig) withDefaults() IdempotencyConfig {
+	if config.TTL == 0 {
+		config.TTL = defaultIdempotencyTTL
+	}
+	if config.LockTTL == 0 {
+		config.LockTTL = defaultIdempotencyLockTTL
+	}
+	if len(config.Methods) == 0 {
+		config.Methods = []string{http.MethodPost, http.MethodPatch}
+	}
+	return config
+}
+
+func (config IdempotencyConfig) appliesTo(method string) bool {
+	for _, m := range config.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// requestFingerprint hashes the method, the path and the body of the request. The body can still be read afterwards.
+func requestFingerprint(c echo.Context) (string, error) {
+	req := c.Request()
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", req.Method, req.URL.Path)
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		hash.Write(body)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replayResponse sends the stored response if there is one. It returns true if a response was sent.
+func replayResponse(c echo.Context, store cache.Cache, key string, fingerprint string) (bool, error) {
+	stored := storedResponse{}
+	err := store.GetJSON(key, &stored)
+	if errors.Is(err, cache.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load idempotent response: %w", err)
+	}
+
+	if stored.Fingerprint != fingerprint {
+		return false, ErrIdempotencyKeyMismatch
+	}
+
+	header := c.Response().Header()
+	for name, values := range stored.Header {
+		header[name] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	c.Response().WriteHeader(stored.Status)
+	_, err = c.Response().Write(stored.Body)
+	return true, err
+}
+
+// handleIdempotent calls the handler while holding the lock for the key and stores the response afterwards.
+func handleIdempotent(c echo.Context, next echo.HandlerFunc, config IdempotencyConfig, locker cache.Locker, key string, fingerprint string) error {
+	unlock, err := lockIdempotencyKey(c, locker, key, config.LockTTL)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another request might have stored its response between the first lookup and acquiring the lock.
+	replayed, err := replayResponse(c, config.Cache, key, fingerprint)
+	if replayed || err != nil {
+		return err
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+	c.Response().Writer = recorder
+	defer func() {
+		c.Response().Writer = recorder.ResponseWriter
+	}()
+
+	if err := next(c); err != nil {
+		return err
+	}
+
+	status := c.Response().Status
+	if !c.Response().Committed || status >= http.StatusInternalServerError {
+		return nil
+	}
+
+	err = config.Cache.SetJSON(key, storedResponse{
+		Fingerprint: fingerprint,
+		Status:      status,
+		Header:      c.Response().Header().Clone(),
+		Body:        recorder.body.Bytes(),
+	}, config.TTL)
+	if err != nil {
+		// The response was already sent, a retry would be handled again.
+		c.Logger().Error(fmt.Errorf("failed to store idempotent response: %w", err))
+	}
+	return nil
+}
+
+// lockIdempotencyKey acquires the lock for the key with a token that is unique for the request. The returned function
+// only releases the lock if it is still held by the request, so a lock that expired during a slow handler and was
+// acquired by another request is not removed.
+func lockIdempotencyKey(c echo.Context, locker cache.Locker, key string, ttl time.Duration) (func(), error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock idempotency key: %w", err)
+	}
+	lockKey := key + ":lock"
+	locked, err := locker.SetNX(lockKey, token, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock idempotency key: %w", err)
+	}
+	if !locked {
+		return nil, ErrIdempotencyKeyInUse
+	}
+
+	return func() {
+		if _, err := locker.CompareAndDelete(lockKey, token); err != nil {
+			c.Logger().Error(fmt.Errorf("failed to unlock idempotency key: %w", err))
+		}
+	}, nil
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// responseRecorder keeps a copy of the response body.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestIdempotency(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err, "error in test setup")
+	defer redisServer.Close()
+	client, err := cache.NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
+	require.NoError(t, err, "error in test setup")
+
+	e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+
+	var calls int64
+	release := make(chan struct{})
+	idempotency := Idempotency(IdempotencyConfig{Cache: client, TTL: time.Hour})
+	e.POST("/invoices", func(c echo.Context) error {
+		n := atomic.AddInt64(&calls, 1)
+		if c.QueryParam("wait") != "" {
+			<-release
+		}
+		c.Response().Header().Set("X-Invoice-Number", "1")
+		return c.JSON(http.StatusCreated, map[string]int64{"call": n})
+	}, idempotency)
+	e.GET("/invoices", func(c echo.Context) error {
+		atomic.AddInt64(&calls, 1)
+		return c.NoContent(http.StatusOK)
+	}, idempotency)
+	e.POST("/failing", func(c echo.Context) error {
+		atomic.AddInt64(&calls, 1)
+		if c.QueryParam("fail") == "error" {
+			return errors.New("testError")
+		}
+		return c.NoContent(http.StatusServiceUnavailable)
+	}, idempotency)
+	e.POST("/required", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, Idempotency(IdempotencyConfig{Cache: client, Required: true}))
+
+	call := func(method, target, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(HeaderIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		first := call(http.MethodPost, "/invoices", "key1", `{"amount":1}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Equal(t, `{"call":1}`+"\n", first.Body.String())
+		assert.Empty(t, first.Header().Get(HeaderIdempotentReplayed))
+
+		second := call(http.MethodPost, "/invoices", "key1", `{"amount":1}`)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, `{"call":1}`+"\n", second.Body.String())
+		assert.Equal(t, "1", second.Header().Get("X-Invoice-Number"))
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, second.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "true", second.Header().Get(HeaderIdempotentReplayed))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls))
+
+		assert.True(t, redisServer.Exists("testPrefix:idempotency:key1"))
+		assert.False(t, redisServer.Exists("testPrefix:idempotency:key1:lock"))
+		redisServer.FastForward(2 * time.Hour)
+		assert.Equal(t, `{"call":2}`+"\n", call(http.MethodPost, "/invoices", "key1", `{"amount":1}`).Body.String())
+	})
+
+	t.Run("different payload", func(t *testing.T) {
+		call(http.MethodPost, "/invoices", "key2", `{"amount":1}`)
+		rec := call(http.MethodPost, "/invoices", "key2", `{"amount":2}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	})
+
+	t.Run("in progress", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- call(http.MethodPost, "/invoices?wait=1", "key3", `{}`)
+		}()
+		require.Eventually(t, func() bool {
+			return redisServer.Exists("testPrefix:idempotency:key3:lock")
+		}, time.Second, time.Millisecond)
+
+		assert.Equal(t, http.StatusConflict, call(http.MethodPost, "/invoices?wait=1", "key3", `{}`).Code)
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("not applied", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		call(http.MethodPost, "/invoices", "", `{}`)
+		call(http.MethodPost, "/invoices", "", `{}`)
+		call(http.MethodGet, "/invoices", "key4", "")
+		call(http.MethodGet, "/invoices", "key4", "")
+		assert.Equal(t, int64(4), atomic.LoadInt64(&calls))
+	})
+
+	t.Run("failures are not stored", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		assert.Equal(t, http.StatusServiceUnavailable, call(http.MethodPost, "/failing", "key5", "").Code)
+		assert.Equal(t, http.StatusServiceUnavailable, call(http.MethodPost, "/failing", "key5", "").Code)
+		assert.Equal(t, http.StatusInternalServerError, call(http.MethodPost, "/failing?fail=error", "key6", "").Code)
+		assert.Equal(t, http.StatusInternalServerError, call(http.MethodPost, "/failing?fail=error", "key6", "").Code)
+		assert.Equal(t, int64(4), atomic.LoadInt64(&calls))
+		assert.False(t, redisServer.Exists("testPrefix:idempotency:key6:lock"))
+	})
+
+	t.Run("required", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, call(http.MethodPost, "/required", "", "").Code)
+		assert.Equal(t, http.StatusOK, call(http.MethodPost, "/required", "key7", "").Code)
+	})
+
+	t.Run("scope", func(t *testing.T) {
+		e := echo.New()
+		e.POST("/", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Request().Header.Get("User"))
+		}, Idempotency(IdempotencyConfig{Cache: client, Scope: func(c echo.Context) string {
+			return c.Request().Header.Get("User")
+		}}))
+
+		for _, user := range []string{"a", "b"} {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set(HeaderIdempotencyKey, "key8")
+			req.Header.Set("User", user)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, user, rec.Body.String())
+		}
+		assert.True(t, redisServer.Exists("testPrefix:idempotency:b:key8"))
+	})
+	t.Run("response stored while acquiring the lock", func(t *testing.T) {
+		atomic.StoreInt64(&calls, 0)
+		assert.Equal(t, http.StatusCreated, call(http.MethodPost, "/invoices", "key9", `{}`).Code)
+
+		// The first lookup misses the stored response, as if it was stored right after the lookup.
+		racingCache := &missingOnceCache{RedisClient: client}
+		e := echo.New()
+		e.POST("/invoices", func(c echo.Context) error {
+			atomic.AddInt64(&calls, 1)
+			return c.NoContent(http.StatusCreated)
+		}, Idempotency(IdempotencyConfig{Cache: racingCache}))
+
+		req := httptest.NewRequest(http.MethodPost, "/invoices", strings.NewReader(`{}`))
+		req.Header.Set(HeaderIdempotencyKey, "key9")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, "true", rec.Header().Get(HeaderIdempotentReplayed))
+		assert.Equal(t, int64(1), atomic.LoadInt64(&calls), "the handler must not be called again")
+		assert.False(t, redisServer.Exists("testPrefix:idempotency:key9:lock"))
+	})
+
+	t.Run("expired lock of another request is kept", func(t *testing.T) {
+		e := echo.New()
+		e.POST("/slow", func(c echo.Context) error {
+			// The lock expired while the handler was running and was acquired by another request.
+			redisServer.Del("testPrefix:idempotency:key10:lock")
+			require.NoError(t, redisServer.Set("testPrefix:idempotency:key10:lock", "otherToken"))
+			return c.NoContent(http.StatusCreated)
+		}, idempotency)
+
+		req := httptest.NewRequest(http.MethodPost, "/slow", nil)
+		req.Header.Set(HeaderIdempotencyKey, "key10")
+		e.ServeHTTP(httptest.NewRecorder(), req)
+
+		lock, err := redisServer.Get("testPrefix:idempotency:key10:lock")
+		require.NoError(t, err)
+		assert.Equal(t, "otherToken", lock)
+	})
+
+	t.Run("cache without locks", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Idempotency(IdempotencyConfig{Cache: struct{ cache.Cache }{client}})
+		})
+	})
+}
+
+// missingOnceCache reports the first lookup of a JSON value as not found.
+type missingOnceCache struct {
+	*cache.RedisClient
+	looked bool
+}
+
+func (m *missingOnceCache) GetJSON(key string, result interface{}) error {
+	if !m.looked {
+		m.looked = true
+		return cache.ErrNotFound
+	}
+	return m.RedisClient.GetJSON(key, result)
+}
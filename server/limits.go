@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// DefaultBodyLimit is the maximum size of request bodies in bytes for servers created via NewWithOptions,
+	// see WithBodyLimit.
+	DefaultBodyLimit = 10 << 20
+
+	timeoutParentKey = "toolkit.timeoutParent"
+)
+
+// ErrBodyTooLarge is returned when reading a request body that exceeds the limit set via BodyLimit.
+var ErrBodyTooLarge = httperrors.New(http.StatusRequestEntityTooLarge, "request body too large")
+
+// TimeoutError is returned by the Timeout middleware when the handler did not finish before the deadline.
+// It results in a 503 response.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("handler did not finish within %s", e.Timeout)
+}
+
+// StatusCode returns 503.
+func (e *TimeoutError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// ErrorCode returns "timeout".
+func (e *TimeoutError) ErrorCode() string {
+	return "timeout"
+}
+
+// Unwrap allows to check for context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Timeout returns a middleware that sets a deadline on the context of the request. Handlers need to pass
+// c.Request().Context() to database queries, HTTP calls etc. so they are cancelled when the deadline is reached.
+// If the handler returns after the deadline without having sent a response, a TimeoutError is returned.
+// The handler itself is not stopped: a handler that ignores the context keeps running and the TimeoutError is only
+// returned once it finished. It can be applied to route groups or single routes to replace the timeout configured via WithHandlerTimeout.
+func Timeout(timeout time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			// A timeout applied to a group replaces the global one instead of being limited by it.
+			parent, ok := c.Get(timeoutParentKey).(context.Context)
+			if !ok {
+				parent = c.Request().Context()
+				c.Set(timeoutParentKey, parent)
+			}
+
+			ctx, cancel := context.WithTimeout(parent, timeout)
+			defer cancel()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+
+			var timeoutErr *TimeoutError
+			if errors.As(err, &timeoutErr) || c.Response().Committed {
+				return err
+			}
+			if errors.Is(c.Request().Context().Err(), context.DeadlineExceeded) {
+				return &TimeoutError{Timeout: timeout}
+			}
+			return err
+		}
+	}
+}
+
+// BodyLimit returns a middleware that limits the size of request bodies to the given number of bytes.
+// Reading more than the limit results in ErrBodyTooLarge (413), also when binding the request. If the Content-Length
+// exceeds the limit, the error is returned on the first read without reading the body. It can be applied to route
+// groups or single routes to replace the limit configured via WithBodyLimit.
+func BodyLimit(limit int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			// A limit applied to a group replaces the global one instead of being limited by it.
+			body := req.Body
+			if limited, ok := body.(*limitedBody); ok {
+				body = limited.ReadCloser
+			}
+			if body != nil && body != http.NoBody {
+				remaining := limit
+				if req.ContentLength > limit {
+					remaining = -1
+				}
+				req.Body = &limitedBody{ReadCloser: body, remaining: remaining}
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// limitedBody returns ErrBodyTooLarge once more than the remaining bytes were read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// Read one byte more than allowed to detect bodies that exceed the limit.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), ErrBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestTimeout(t *testing.T) {
+	testLogger := observance.NewTestLogger()
+	e, _, err := NewWithOptions(&observance.Obs{Logger: testLogger}, WithHandlerTimeout(20*time.Millisecond))
+	require.NoError(t, err)
+
+	waitForDeadline := func(c echo.Context) error {
+		select {
+		case <-c.Request().Context().Done():
+			return c.Request().Context().Err()
+		case <-time.After(time.Second):
+			return c.NoContent(http.StatusOK)
+		}
+	}
+	e.GET("/slow", waitForDeadline)
+	e.GET("/ignores-context", func(c echo.Context) error {
+		time.Sleep(40 * time.Millisecond)
+		return nil
+	})
+	e.GET("/fast", func(c echo.Context) error {
+		_, hasDeadline := c.Request().Context().Deadline()
+		assert.True(t, hasDeadline)
+		return c.NoContent(http.StatusOK)
+	})
+	e.GET("/longer", waitForDeadline, Timeout(50*time.Millisecond))
+	e.GET("/upstream", func(c echo.Context) error {
+		return context.DeadlineExceeded
+	})
+
+	cases := []struct {
+		route        string
+		expectedCode int
+		minDuration  time.Duration
+	}{
+		{"/slow", http.StatusServiceUnavailable, 20 * time.Millisecond},
+		{"/ignores-context", http.StatusServiceUnavailable, 40 * time.Millisecond},
+		{"/fast", http.StatusOK, 0},
+		{"/longer", http.StatusServiceUnavailable, 50 * time.Millisecond},
+		{"/upstream", http.StatusGatewayTimeout, 0},
+	}
+	for _, test := range cases {
+		t.Run(test.route, func(t *testing.T) {
+			start := time.Now()
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.route, nil))
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			assert.GreaterOrEqual(t, int64(time.Since(start)), int64(test.minDuration))
+		})
+	}
+
+	t.Run("response body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+		assert.Equal(t, `{"message":"handler did not finish within 20ms","code":"timeout"}`, strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("server timeout by default", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: testLogger}, WithTimeout(time.Minute))
+		require.NoError(t, err)
+		e.GET("/", func(c echo.Context) error {
+			deadline, hasDeadline := c.Request().Context().Deadline()
+			assert.True(t, hasDeadline)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+			return nil
+		})
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: testLogger}, WithHandlerTimeout(0))
+		require.NoError(t, err)
+		e.GET("/", func(c echo.Context) error {
+			_, hasDeadline := c.Request().Context().Deadline()
+			assert.False(t, hasDeadline)
+			return nil
+		})
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("not set by New", func(t *testing.T) {
+		e, _, err := New(&observance.Obs{Logger: testLogger}, "")
+		require.NoError(t, err)
+		e.GET("/", func(c echo.Context) error {
+			_, hasDeadline := c.Request().Context().Deadline()
+			assert.False(t, hasDeadline)
+			return nil
+		})
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestBodyLimit(t *testing.T) {
+	e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()}, WithBodyLimit(10))
+	require.NoError(t, err)
+
+	read := func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, string(body))
+	}
+	bind := func(c echo.Context) error {
+		payload := struct {
+			A string `json:"a"`
+		}{}
+		if err := c.Bind(&payload); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, payload)
+	}
+	e.POST("/read", read)
+	e.POST("/bind", bind)
+	uploads := e.Group("/uploads", BodyLimit(20))
+	uploads.POST("/read", read)
+
+	cases := []struct {
+		name          string
+		route         string
+		body          string
+		contentLength bool
+		expectedCode  int
+		expectedBody  string
+	}{
+		{"within limit", "/read", "1234567890", true, http.StatusOK, "1234567890"},
+		{"content length too large", "/read", "12345678901", true, http.StatusRequestEntityTooLarge, `{"message":"request body too large"}`},
+		{"chunked body too large", "/read", "12345678901", false, http.StatusRequestEntityTooLarge, `{"message":"request body too large"}`},
+		{"bind too large", "/bind", `{"a":"12345678"}`, false, http.StatusRequestEntityTooLarge, `{"message":"request body too large"}`},
+		{"bind within limit", "/bind", `{"a":"1"}`, false, http.StatusOK, `{"a":"1"}`},
+		{"group limit", "/uploads/read", "123456789012345", true, http.StatusOK, "123456789012345"},
+		{"group limit exceeded", "/uploads/read", "123456789012345678901", false, http.StatusRequestEntityTooLarge, `{"message":"request body too large"}`},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, test.route, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if !test.contentLength {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			assert.Equal(t, test.expectedBody, strings.TrimSpace(rec.Body.String()))
+		})
+	}
+}
+
+func TestDefaultBodyLimit(t *testing.T) {
+	read := func(c echo.Context) error {
+		body, err := ioutil.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, strconv.Itoa(len(body)))
+	}
+	body := strings.Repeat("a", DefaultBodyLimit+1)
+
+	t.Run("NewWithOptions", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+		require.NoError(t, err)
+		e.POST("/", read)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()}, WithBodyLimit(0))
+		require.NoError(t, err)
+		e.POST("/", read)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, strconv.Itoa(len(body)), rec.Body.String())
+	})
+
+	t.Run("not limited by New", func(t *testing.T) {
+		e, _, err := New(&observance.Obs{Logger: observance.NewTestLogger()}, "")
+		require.NoError(t, err)
+		e.POST("/", read)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// LogLevelRoute is the route under which the log level endpoints are registered.
+const LogLevelRoute = "/admin/loglevel"
+
+// LogLevelHandler provides endpoints to read and change the log level of the service at runtime.
+// A changed level can be reverted automatically after a given duration so debug logging
+// in production is not forgotten.
+type LogLevelHandler struct {
+	obs                *observance.Obs
+	defaultRevertAfter time.Duration
+
+	mu          sync.Mutex
+	revertTimer *time.Timer
+	// revertID identifies the currently scheduled revert so an outdated timer that already fired does nothing.
+	revertID    int
+	revertLevel string
+	revertAt    time.Time
+}
+
+type logLevelRequest struct {
+	Level string `json:"level" validate:"required"`
+	// RevertAfter is a duration like "10m". If it is empty the default of the handler is used.
+	// "0s" disables the automatic revert.
+	RevertAfter string `json:"revertAfter"`
+}
+
+type logLevelResponse struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revertAt,omitempty"`
+}
+
+// NewLogLevelHandler creates a handler for the log level endpoints.
+// defaultRevertAfter is used if a request does not contain a revert duration, 0 means the change is permanent.
+func NewLogLevelHandler(obs *observance.Obs, defaultRevertAfter time.Duration) *LogLevelHandler {
+	return &LogLevelHandler{
+		obs:                obs,
+		defaultRevertAfter: defaultRevertAfter,
+	}
+}
+
+// Register adds the endpoints GET and PUT /admin/loglevel to the echo server.
+// The endpoints should not be publicly accessible, use the middleware to protect them.
+func (h *LogLevelHandler) Register(echoServer *echo.Echo, middleware ...echo.MiddlewareFunc) {
+	echoServer.GET(LogLevelRoute, h.Get, middleware...)
+	echoServer.PUT(LogLevelRoute, h.Put, middleware...)
+}
+
+// Get returns the current log level and the time it will be reverted if a revert is pending.
+func (h *LogLevelHandler) Get(c echo.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return c.JSON(http.StatusOK, h.response())
+}
+
+// Put changes the log level. The request body needs to contain the new level and optionally the revert duration,
+// e.g. {"level": "debug", "revertAfter": "15m"}.
+// If multiple changes are made before a revert happened, the level is reverted to the one from before the first change.
+func (h *LogLevelHandler) Put(c echo.Context) error {
+	req := &logLevelRequest{}
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	revertAfter := h.defaultRevertAfter
+	if req.RevertAfter != "" {
+		parsedRevertAfter, err := time.ParseDuration(req.RevertAfter)
+		if err != nil {
+			return httperrors.New(http.StatusBadRequest, fmt.Sprintf("revertAfter could not be parsed: %s", err))
+		}
+		revertAfter = parsedRevertAfter
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previousLevel := h.obs.Logger.Level()
+	if err := h.obs.SetLevel(req.Level); err != nil {
+		return httperrors.New(http.StatusBadRequest, err.Error())
+	}
+
+	if h.revertTimer != nil {
+		h.revertTimer.Stop()
+		h.revertTimer = nil
+		// Keep the level from before the first change as target for the revert.
+		previousLevel = h.revertLevel
+	}
+
+	if revertAfter > 0 {
+		h.revertLevel = previousLevel
+		h.revertAt = time.Now().Add(revertAfter)
+		h.revertID++
+		revertID := h.revertID
+		h.revertTimer = time.AfterFunc(revertAfter, func() { h.revert(revertID) })
+	}
+
+	h.obs.Logger.WithFields(observance.Fields{
+		"level":       req.Level,
+		"revertAfter": revertAfter.String(),
+	}).Warn("log level changed")
+
+	return c.JSON(http.StatusOK, h.response())
+}
+
+func (h *LogLevelHandler) revert(revertID int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.revertTimer == nil || revertID != h.revertID {
+		return
+	}
+
+	if err := h.obs.SetLevel(h.revertLevel); err != nil {
+		h.obs.Logger.WithError(err).Error("failed to revert log level")
+	} else {
+		h.obs.Logger.WithField("level", h.revertLevel).Warn("log level reverted")
+	}
+	h.revertTimer = nil
+}
+
+// response needs to be called while holding the lock.
+func (h *LogLevelHandler) response() logLevelResponse {
+	resp := logLevelResponse{Level: h.obs.Logger.Level()}
+	if h.revertTimer != nil {
+		revertAt := h.revertAt
+		resp.RevertAt = &revertAt
+	}
+	return resp
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestLogLevelHandler(t *testing.T) {
+	setup := func(t *testing.T, defaultRevertAfter time.Duration) (*observance.Obs, func(method string, body string) (int, logLevelResponse)) {
+		obs := &observance.Obs{Logger: observance.NewTestLogger()}
+		require.NoError(t, obs.SetLevel("info"))
+		e, _, err := New(obs, "")
+		require.NoError(t, err)
+		NewLogLevelHandler(obs, defaultRevertAfter).Register(e)
+
+		call := func(method string, body string) (int, logLevelResponse) {
+			req := httptest.NewRequest(method, LogLevelRoute, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			resp := logLevelResponse{}
+			if rec.Code == http.StatusOK {
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			}
+			return rec.Code, resp
+		}
+		return obs, call
+	}
+
+	t.Run("get", func(t *testing.T) {
+		_, call := setup(t, 0)
+		code, resp := call(http.MethodGet, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "info", resp.Level)
+		assert.Nil(t, resp.RevertAt)
+	})
+
+	t.Run("permanent change", func(t *testing.T) {
+		obs, call := setup(t, 0)
+		code, resp := call(http.MethodPut, `{"level":"debug"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "debug", resp.Level)
+		assert.Nil(t, resp.RevertAt)
+		assert.Equal(t, "debug", obs.Logger.Level())
+	})
+
+	t.Run("change with revert", func(t *testing.T) {
+		obs, call := setup(t, time.Hour)
+		code, resp := call(http.MethodPut, `{"level":"debug","revertAfter":"50ms"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "debug", resp.Level)
+		require.NotNil(t, resp.RevertAt)
+		assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), *resp.RevertAt, time.Second)
+
+		// A second change keeps the original level as revert target.
+		code, _ = call(http.MethodPut, `{"level":"trace","revertAfter":"50ms"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "trace", obs.Logger.Level())
+
+		assert.Eventually(t, func() bool {
+			return obs.Logger.Level() == "info"
+		}, time.Second, 10*time.Millisecond)
+
+		_, resp = call(http.MethodGet, "")
+		assert.Nil(t, resp.RevertAt)
+	})
+
+	t.Run("default revert duration", func(t *testing.T) {
+		_, call := setup(t, time.Hour)
+		_, resp := call(http.MethodPut, `{"level":"debug"}`)
+		require.NotNil(t, resp.RevertAt)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *resp.RevertAt, time.Second)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		obs, call := setup(t, 0)
+		cases := []string{
+			`{"level":"verbose"}`,
+			`{"level":""}`,
+			`{"level":"debug","revertAfter":"soon"}`,
+		}
+		for _, body := range cases {
+			code, _ := call(http.MethodPut, body)
+			assert.Equal(t, http.StatusBadRequest, code, body)
+		}
+		assert.Equal(t, "info", obs.Logger.Level())
+	})
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"time"
+
+	"github.com/fastbill/go-service-toolkit/v4/health"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
+)
+
+const (
+	defaultShutdownTimeout = 9 * time.Second
+	defaultIdleTimeout     = 120 * time.Second
+)
+
+// DefaultMiddleware identifies a middleware that is applied by default and can be removed via WithoutMiddleware.
+type DefaultMiddleware string
+
+// Middleware that is applied by default.
+const (
+	// MiddlewareRemoveTrailingSlash removes trailing slashes from the request URL.
+	MiddlewareRemoveTrailingSlash DefaultMiddleware = "removeTrailingSlash"
+	// MiddlewareSecure sets security related headers like X-XSS-Protection.
+	MiddlewareSecure DefaultMiddleware = "secure"
+	// MiddlewareRecover recovers panics in the handlers, see Recover.
+	MiddlewareRecover DefaultMiddleware = "recover"
+)
+
+// Option configures the server created by NewWithOptions.
+type Option func(*config)
+
+// config holds all settings that can be changed via the options.
+type config struct {
+	corsOrigins        []string
+	cors               *CORSConfig
+	timeout            time.Duration
+	idleTimeout        time.Duration
+	shutdownTimeout    time.Duration
+	http2              bool
+	h2c                bool
+	tlsCertFile        string
+	tlsKeyFile         string
+	disabledMiddleware map[DefaultMiddleware]bool
+	health             *health.Health
+	admin              *AdminServer
+	shutdownManager    *shutdown.Manager
+	errorResponses     ErrorResponseConfig
+	handlerTimeout     time.Duration
+	bodyLimit          int64
+	binder             BinderConfig
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{
+		timeout:            defaultTimeout,
+		shutdownTimeout:    defaultShutdownTimeout,
+		disabledMiddleware: map[DefaultMiddleware]bool{},
+		// A negative handler timeout means that the timeout of the server is used.
+		handlerTimeout: -1,
+		bodyLimit:      DefaultBodyLimit,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.handlerTimeout < 0 {
+		cfg.handlerTimeout = cfg.timeout
+	}
+	return cfg
+}
+
+// WithCORS adds the CORS middleware of echo with the given allowed origins, they are passed to echo as they are.
+// Without this option no CORS middleware is applied and same-origin restrictions apply.
+// WithCORSConfig allows to configure the other CORS settings and validates the origins.
+func WithCORS(origins ...string) Option {
+	return func(cfg *config) {
+		cfg.corsOrigins = origins
+		cfg.cors = nil
+	}
+}
+
+// WithCORSConfig adds the CORS middleware with the given config, e.g. to allow credentials or custom headers,
+// see CORS. Use CORSConfigFromEnv to read the config from environment variables. It replaces WithCORS.
+func WithCORSConfig(corsConfig CORSConfig) Option {
+	return func(cfg *config) {
+		cfg.cors = &corsConfig
+		cfg.corsOrigins = nil
+	}
+}
+
+// WithTimeout sets the timeout for reading the headers, reading the request and writing the response.
+// The default is 30 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithIdleTimeout sets the maximum amount of time to wait for the next request when keep-alives are enabled.
+// By default it is 120 seconds or the timeout set via WithTimeout if that is longer.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.idleTimeout = timeout
+	}
+}
+
+// WithShutdownTimeout sets how long the graceful shutdown waits for ongoing requests to finish.
+// The default is 9 seconds.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.shutdownTimeout = timeout
+	}
+}
+
+// WithHTTP2 enables HTTP/2 which is disabled by default. It applies to TLS connections (see WithTLS),
+// use WithH2C for HTTP/2 without TLS.
+func WithHTTP2() Option {
+	return func(cfg *config) {
+		cfg.http2 = true
+	}
+}
+
+// WithH2C enables HTTP/2 without TLS (h2c), e.g. for the traffic within a service mesh that handles TLS itself.
+// Clients can either use prior knowledge or upgrade the HTTP/1.1 connection. Plain HTTP/1.1 requests are still served.
+// The server needs to be started via Start.
+func WithH2C() Option {
+	return func(cfg *config) {
+		cfg.h2c = true
+	}
+}
+
+// WithTLS serves TLS with the certificate and key from the given PEM encoded files, the server needs to be
+// started via Start. The files are watched and the certificate is reloaded when they change, see CertReloader.
+func WithTLS(certFile, keyFile string) Option {
+	return func(cfg *config) {
+		cfg.tlsCertFile = certFile
+		cfg.tlsKeyFile = keyFile
+	}
+}
+
+// WithoutMiddleware removes the given middleware that would be applied by default.
+func WithoutMiddleware(middleware ...DefaultMiddleware) Option {
+	return func(cfg *config) {
+		for _, m := range middleware {
+			cfg.disabledMiddleware[m] = true
+		}
+	}
+}
+
+// WithHealth registers the liveness (/healthz) and readiness (/readyz) endpoints of the given health instance.
+// As soon as the graceful shutdown begins, the readiness endpoint reports the service as not ready.
+func WithHealth(h *health.Health) Option {
+	return func(cfg *config) {
+		cfg.health = h
+	}
+}
+
+// WithAdminServer starts the given admin server together with the main server and shuts it down with it.
+// The main server needs to be started via Start. If health checks are configured via WithHealth, their endpoints
+// are registered on the admin server instead of the main server.
+func WithAdminServer(admin *AdminServer) Option {
+	return func(cfg *config) {
+		cfg.admin = admin
+	}
+}
+
+// WithShutdownManager hands the graceful shutdown of the server over to the given shutdown manager.
+// Instead of listening for signals itself, the server registers a hook with shutdown.OrderServer.
+// The shutdown timeout of the server still applies within the total deadline of the manager.
+func WithShutdownManager(m *shutdown.Manager) Option {
+	return func(cfg *config) {
+		cfg.shutdownManager = m
+	}
+}
+
+// WithErrorResponses configures the format of the error responses, e.g. to send RFC 7807 problem details
+// to clients that accept application/problem+json. By default the format of httperrors.HTTPError is used.
+func WithErrorResponses(errorConfig ErrorResponseConfig) Option {
+	return func(cfg *config) {
+		cfg.errorResponses = errorConfig
+	}
+}
+
+// WithHandlerTimeout sets the deadline for the context of each request, see Timeout.
+// By default the timeout set via WithTimeout is used, so the work for requests whose connection was already
+// closed gets cancelled. Handlers that do not pass on the context are not stopped by the deadline.
+// A timeout of 0 disables the deadline.
+func WithHandlerTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.handlerTimeout = timeout
+	}
+}
+
+// WithBodyLimit sets the maximum size of request bodies in bytes, see BodyLimit.
+// The default is DefaultBodyLimit (10 MB). A limit of 0 disables the check.
+func WithBodyLimit(limit int64) Option {
+	return func(cfg *config) {
+		cfg.bodyLimit = limit
+	}
+}
+
+// WithBinder configures the Binder that is used for c.Bind, e.g. to reject unknown fields in JSON bodies.
+func WithBinder(binderConfig BinderConfig) Option {
+	return func(cfg *config) {
+		cfg.binder = binderConfig
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/health"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+
+	t.Run("defaults", func(t *testing.T) {
+		e, connsClosed, err := NewWithOptions(obs)
+		require.NoError(t, err)
+		assert.NotNil(t, connsClosed)
+		assert.Equal(t, 30*time.Second, e.Server.ReadTimeout)
+		assert.Equal(t, 30*time.Second, e.Server.WriteTimeout)
+		assert.Equal(t, 30*time.Second, e.Server.ReadHeaderTimeout)
+		assert.Equal(t, 120*time.Second, e.Server.IdleTimeout)
+		assert.True(t, e.DisableHTTP2)
+
+		rec := serve(e, "/test/", nil)
+		assert.Equal(t, http.StatusOK, rec.Code, "trailing slash should be removed")
+		assert.Equal(t, "1; mode=block", rec.Header().Get(echo.HeaderXXSSProtection))
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		e, _, err := NewWithOptions(obs, WithTimeout(3*time.Minute), WithShutdownTimeout(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, 3*time.Minute, e.Server.ReadTimeout)
+		assert.Equal(t, 3*time.Minute, e.Server.WriteTimeout)
+		assert.Equal(t, time.Duration(0), e.Server.IdleTimeout, "falls back to the read timeout")
+
+		e, _, err = NewWithOptions(obs, WithIdleTimeout(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, e.Server.IdleTimeout)
+	})
+
+	t.Run("HTTP2", func(t *testing.T) {
+		e, _, err := NewWithOptions(obs, WithHTTP2())
+		require.NoError(t, err)
+		assert.False(t, e.DisableHTTP2)
+	})
+
+	t.Run("CORS", func(t *testing.T) {
+		e, _, err := NewWithOptions(obs, WithCORS("https://example.com", "https://example.org"))
+		require.NoError(t, err)
+
+		rec := serve(e, "/test", map[string]string{echo.HeaderOrigin: "https://example.org"})
+		assert.Equal(t, "https://example.org", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+
+		rec = serve(e, "/test", map[string]string{echo.HeaderOrigin: "https://other.com"})
+		assert.Empty(t, rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		e, _, err := NewWithOptions(obs, WithHealth(health.New(health.Config{})))
+		require.NoError(t, err)
+
+		for _, route := range []string{health.LivenessRoute, health.ReadinessRoute} {
+			rec := serve(e, route, nil)
+			assert.Equal(t, http.StatusOK, rec.Code, route)
+		}
+	})
+
+	t.Run("shutdown manager", func(t *testing.T) {
+		m := shutdown.New(shutdown.Config{}, obs.Logger)
+		h := health.New(health.Config{})
+		_, connsClosed, err := NewWithOptions(obs, WithShutdownManager(m), WithHealth(h))
+		require.NoError(t, err)
+
+		m.Shutdown()
+		assert.NoError(t, m.Wait())
+		assert.Equal(t, health.StatusFailing, h.Readiness(context.Background()).Status)
+		select {
+		case <-connsClosed:
+		default:
+			assert.Fail(t, "connections closed channel was not closed")
+		}
+	})
+
+	t.Run("without middleware", func(t *testing.T) {
+		e, _, err := NewWithOptions(obs, WithoutMiddleware(MiddlewareSecure, MiddlewareRemoveTrailingSlash))
+		require.NoError(t, err)
+
+		rec := serve(e, "/test/", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Header().Get(echo.HeaderXXSSProtection))
+	})
+}
+
+func TestNewCompatibility(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+
+	t.Run("timeout and CORS", func(t *testing.T) {
+		e, _, err := New(obs, "https://example.com,https://example.org", "1m")
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, e.Server.ReadTimeout)
+
+		rec := serve(e, "/test", map[string]string{echo.HeaderOrigin: "https://example.org"})
+		assert.Equal(t, "https://example.org", rec.Header().Get(echo.HeaderAccessControlAllowOrigin))
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, _, err := New(obs, "", "soon")
+		assert.Error(t, err)
+	})
+}
+
+// serve registers a test route on the server and sends a GET request with the given headers to the path.
+func serve(e *echo.Echo, path string, headers map[string]string) *httptest.ResponseRecorder {
+	e.GET("/test", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
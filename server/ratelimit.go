@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// Headers that are set by the RateLimit middleware.
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+)
+
+// ErrRateLimitExceeded is returned by the RateLimit middleware if a client sent too many requests.
+var ErrRateLimitExceeded = httperrors.New(http.StatusTooManyRequests, "rate limit exceeded")
+
+// RateLimitKeyFunc returns the key that identifies the client whose requests are counted.
+type RateLimitKeyFunc func(c echo.Context) string
+
+// KeyByIP identifies clients by their IP address, see echo.Context.RealIP.
+func KeyByIP() RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		return "ip:" + c.RealIP()
+	}
+}
+
+// KeyByHeader identifies clients by the value of the given header, e.g. an API key.
+// Requests without the header are identified by their IP address.
+func KeyByHeader(header string) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		if value := c.Request().Header.Get(header); value != "" {
+			return "header:" + value
+		}
+		return KeyByIP()(c)
+	}
+}
+
+// KeyByContext identifies clients by a string value that was stored in the echo context under the given key,
+// e.g. the subject of an authenticated user. Requests without the value are identified by their IP address.
+func KeyByContext(contextKey string) RateLimitKeyFunc {
+	return func(c echo.Context) string {
+		if value, ok := c.Get(contextKey).(string); ok && value != "" {
+			return "context:" + value
+		}
+		return KeyByIP()(c)
+	}
+}
+
+// RateLimitConfig configures the RateLimit middleware.
+type RateLimitConfig struct {
+	// Limiter stores the state, use NewRedisRateLimiter for services with multiple instances.
+	Limiter RateLimiter
+	// KeyFunc identifies the client. The default is KeyByIP.
+	KeyFunc RateLimitKeyFunc
+	// FailClosed rejects requests with a 500 response if the limiter returns an error, e.g. because REDIS
+	// cannot be reached. By default these requests are allowed and the error is logged.
+	FailClosed bool
+	// Obs is used to log the errors of the limiter with the fields of the request.
+	// Without it the logger of echo is used.
+	Obs *observance.Obs
+}
+
+// RateLimit returns a middleware that limits the number of requests per client. Rejected requests receive
+// a 429 response with the Retry-After header. All responses contain the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, the times are in seconds.
+func RateLimit(config RateLimitConfig) echo.MiddlewareFunc {
+	if config.KeyFunc == nil {
+		config.KeyFunc = KeyByIP()
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			result, err := config.Limiter.Allow(c.Request().Context(), config.KeyFunc(c))
+			if err != nil {
+				if config.FailClosed {
+					return fmt.Errorf("rate limit check failed: %w", err)
+				}
+				logRateLimitError(config.Obs, c, fmt.Errorf("rate limit check failed, request is allowed: %w", err))
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderRateLimitReset, seconds(result.ResetAfter))
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, seconds(result.RetryAfter))
+				return ErrRateLimitExceeded
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// seconds rounds up so clients do not retry too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// logRateLimitError logs the error with the fields of the request if an Obs was configured.
+func logRateLimitError(obs *observance.Obs, c echo.Context, err error) {
+	if obs == nil {
+		c.Logger().Error(err)
+		return
+	}
+	obs.CopyWithRequest(c.Request()).Logger.Error(err)
+}
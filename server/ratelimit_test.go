@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestRateLimiters(t *testing.T) {
+	rate := Rate{Limit: 3, Period: 3 * time.Second}
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	memoryLimiter := NewMemoryRateLimiter(rate)
+	memoryLimiter.now = clock
+
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err, "error in test setup")
+	defer redisServer.Close()
+	client, err := cache.NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
+	require.NoError(t, err, "error in test setup")
+	redisLimiter := NewRedisRateLimiter(client, "test", rate)
+	redisLimiter.now = clock
+
+	limiters := map[string]RateLimiter{"memory": memoryLimiter, "redis": redisLimiter}
+	for name, limiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			now = time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+			allow := func(key string) RateLimitResult {
+				result, err := limiter.Allow(context.Background(), key)
+				require.NoError(t, err)
+				return result
+			}
+
+			assert.Equal(t, RateLimitResult{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}, allow("a"))
+			assert.Equal(t, RateLimitResult{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 2 * time.Second}, allow("a"))
+			assert.Equal(t, RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 3 * time.Second}, allow("a"))
+			assert.Equal(t, RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, ResetAfter: 3 * time.Second, RetryAfter: time.Second}, allow("a"))
+
+			// Other clients are not affected.
+			assert.True(t, allow("b").Allowed)
+
+			// After one interval one more request is allowed.
+			now = now.Add(1500 * time.Millisecond)
+			assert.Equal(t, RateLimitResult{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: 2500 * time.Millisecond}, allow("a"))
+			assert.Equal(t, RateLimitResult{Allowed: false, Limit: 3, Remaining: 0, ResetAfter: 2500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}, allow("a"))
+
+			// After the reset the full limit is available again.
+			now = now.Add(time.Minute)
+			assert.Equal(t, 2, allow("a").Remaining)
+		})
+	}
+
+	t.Run("redis keys", func(t *testing.T) {
+		assert.True(t, redisServer.Exists("testPrefix:ratelimit:test:a"))
+		redisServer.FastForward(time.Minute)
+		assert.False(t, redisServer.Exists("testPrefix:ratelimit:test:b"), "keys expire")
+	})
+
+	t.Run("memory keys are removed", func(t *testing.T) {
+		now = now.Add(time.Hour)
+		_, err := memoryLimiter.Allow(context.Background(), "c")
+		require.NoError(t, err)
+		assert.Len(t, memoryLimiter.tats, 1)
+	})
+
+	t.Run("redis context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := redisLimiter.Allow(ctx, "a")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("redis not reachable", func(t *testing.T) {
+		redisServer.Close()
+		_, err := redisLimiter.Allow(context.Background(), "a")
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid rate", func(t *testing.T) {
+		assert.Panics(t, func() { NewMemoryRateLimiter(Rate{Limit: 0, Period: time.Second}) })
+	})
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("testError")
+}
+
+func TestRateLimit(t *testing.T) {
+	testLogger := observance.NewTestLogger()
+	obs := &observance.Obs{Logger: testLogger}
+	e, _, err := NewWithOptions(obs)
+	require.NoError(t, err)
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+
+	e.GET("/ip", ok, RateLimit(RateLimitConfig{Limiter: NewMemoryRateLimiter(Rate{Limit: 1, Period: time.Minute})}))
+	e.GET("/header", ok, RateLimit(RateLimitConfig{
+		Limiter: NewMemoryRateLimiter(Rate{Limit: 1, Period: time.Minute}),
+		KeyFunc: KeyByHeader("X-API-Key"),
+	}))
+	e.GET("/subject", ok, func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("subject", c.QueryParam("user"))
+			return next(c)
+		}
+	}, RateLimit(RateLimitConfig{
+		Limiter: NewMemoryRateLimiter(Rate{Limit: 1, Period: time.Minute}),
+		KeyFunc: KeyByContext("subject"),
+	}))
+	e.GET("/fail-open", ok, RateLimit(RateLimitConfig{Limiter: failingLimiter{}, Obs: obs}))
+	e.GET("/fail-closed", ok, RateLimit(RateLimitConfig{Limiter: failingLimiter{}, FailClosed: true}))
+
+	call := func(target string, ip string, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = ip + ":1234"
+		if header != "" {
+			req.Header.Set("X-API-Key", header)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("headers", func(t *testing.T) {
+		rec := call("/ip", "10.0.0.1", "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "1", rec.Header().Get(HeaderRateLimitLimit))
+		assert.Equal(t, "0", rec.Header().Get(HeaderRateLimitRemaining))
+		assert.Equal(t, "60", rec.Header().Get(HeaderRateLimitReset))
+		assert.Empty(t, rec.Header().Get(echo.HeaderRetryAfter))
+
+		rec = call("/ip", "10.0.0.1", "")
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, `{"message":"rate limit exceeded"}`+"\n", rec.Body.String())
+		assert.Equal(t, "60", rec.Header().Get(echo.HeaderRetryAfter))
+
+		assert.Equal(t, http.StatusOK, call("/ip", "10.0.0.2", "").Code)
+	})
+
+	t.Run("header key", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, call("/header", "10.0.0.1", "key1").Code)
+		assert.Equal(t, http.StatusTooManyRequests, call("/header", "10.0.0.2", "key1").Code)
+		assert.Equal(t, http.StatusOK, call("/header", "10.0.0.1", "key2").Code)
+		assert.Equal(t, http.StatusOK, call("/header", "10.0.0.1", "").Code, "falls back to the IP")
+	})
+
+	t.Run("context key", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, call("/subject?user=1", "10.0.0.1", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, call("/subject?user=1", "10.0.0.2", "").Code)
+		assert.Equal(t, http.StatusOK, call("/subject?user=2", "10.0.0.1", "").Code)
+	})
+
+	t.Run("limiter errors", func(t *testing.T) {
+		testLogger.Reset()
+		assert.Equal(t, http.StatusOK, call("/fail-open", "10.0.0.1", "").Code)
+		entry := testLogger.LastEntry()
+		assert.Contains(t, entry.Message, "testError")
+		assert.Equal(t, "/fail-open", entry.Data["url"], "logged with the fields of the request")
+
+		assert.Equal(t, http.StatusInternalServerError, call("/fail-closed", "10.0.0.1", "").Code)
+	})
+}
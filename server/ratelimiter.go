@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+)
+
+// Rate defines how many requests are allowed per period, e.g. Rate{Limit: 100, Period: time.Minute}.
+// The requests do not need to be evenly distributed, the whole limit can be used at once.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// RateLimitResult describes the outcome of a rate limit check.
+type RateLimitResult struct {
+	// Allowed is false if the request exceeds the limit.
+	Allowed bool
+	// Limit is the number of requests allowed per period.
+	Limit int
+	// Remaining is the number of requests that are still allowed right now.
+	Remaining int
+	// ResetAfter is the time until the full limit is available again.
+	ResetAfter time.Duration
+	// RetryAfter is the time until the next request is allowed, it is only set if the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimiter decides whether a request of the client identified by the key is allowed.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (RateLimitResult, error)
+}
+
+// gcra implements the generic cell rate algorithm. For each key only the theoretical arrival time (TAT)
+// of the next request needs to be stored. A request is allowed if the TAT is at most one period in the future.
+type gcra struct {
+	rate     Rate
+	interval time.Duration
+}
+
+func newGCRA(rate Rate) gcra {
+	if rate.Limit <= 0 || rate.Period <= 0 {
+		panic(fmt.Sprintf("invalid rate %+v, limit and period need to be positive", rate))
+	}
+	return gcra{rate: rate, interval: rate.Period / time.Duration(rate.Limit)}
+}
+
+// result builds the RateLimitResult from the time until the TAT after the request (resetAfter)
+// or the time until the next request is allowed (retryAfter, only if not allowed).
+func (g gcra) result(allowed bool, resetAfter, retryAfter time.Duration) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      g.rate.Limit,
+		ResetAfter: resetAfter,
+	}
+	if allowed {
+		result.Remaining = int((g.rate.Period - resetAfter) / g.interval)
+	} else {
+		result.RetryAfter = retryAfter
+	}
+	return result
+}
+
+// MemoryRateLimiter stores the state in memory. It can be used in tests and for services with a single instance.
+type MemoryRateLimiter struct {
+	gcra
+	now func() time.Time
+
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimiter creates a rate limiter that keeps the state in memory.
+// It panics if the limit or the period of the rate is not positive.
+func NewMemoryRateLimiter(rate Rate) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		gcra: newGCRA(rate),
+		now:  time.Now,
+		tats: map[string]time.Time{},
+	}
+}
+
+// Allow checks and counts the request. It never returns an error.
+func (l *MemoryRateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	tat := l.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(l.interval)
+	allowAt := newTAT.Add(-l.rate.Period)
+	if now.Before(allowAt) {
+		return l.result(false, tat.Sub(now), allowAt.Sub(now)), nil
+	}
+
+	l.tats[key] = newTAT
+	return l.result(true, newTAT.Sub(now), 0), nil
+}
+
+// sweep removes the keys whose TAT is in the past at most once per period, they are not limited anymore.
+func (l *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.rate.Period {
+		return
+	}
+	for key, tat := range l.tats {
+		if tat.Before(now) {
+			delete(l.tats, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// gcraScript implements the same algorithm as the MemoryRateLimiter. All times are in microseconds.
+// The TAT is stored with an expiration so keys of clients that are not limited anymore are removed.
+var gcraScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local period = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - period
+if now < allow_at then
+	return {0, tat - now, allow_at - now}
+end
+
+redis.call('SET', KEYS[1], string.format('%.0f', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, new_tat - now, 0}
+`)
+
+// RedisRateLimiter stores the state in REDIS so the limit applies across all instances of a service.
+// The current time is taken from the instances, so their clocks need to be synchronized.
+type RedisRateLimiter struct {
+	gcra
+	client *cache.RedisClient
+	name   string
+	now    func() time.Time
+}
+
+// NewRedisRateLimiter creates a rate limiter that keeps the state in REDIS. The name is added to the keys,
+// it separates limiters with different rates, e.g. "login". The prefix of the client is applied as well.
+// It panics if the limit or the period of the rate is not positive.
+func NewRedisRateLimiter(client *cache.RedisClient, name string, rate Rate) *RedisRateLimiter {
+	return &RedisRateLimiter{
+		gcra:   newGCRA(rate),
+		client: client,
+		name:   name,
+		now:    time.Now,
+	}
+}
+
+// Allow checks and counts the request. An error is returned if REDIS could not be reached or the context is done.
+func (l *RedisRateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	result, err := l.client.RunScriptContext(ctx, gcraScript, []string{"ratelimit:" + l.name + ":" + key},
+		l.now().UnixMicro(), l.interval.Microseconds(), l.rate.Period.Microseconds())
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("unexpected result of rate limit script: %v", result)
+	}
+	allowed, _ := values[0].(int64)
+	resetAfter, _ := values[1].(int64)
+	retryAfter, _ := values[2].(int64)
+
+	return l.result(allowed == 1, time.Duration(resetAfter)*time.Microsecond, time.Duration(retryAfter)*time.Microsecond), nil
+}
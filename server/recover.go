@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// Recover returns a middleware that recovers panics in the handlers and the middleware applied after it.
+// The panic is logged with the request fields of obs.CopyWithRequest and the stack trace and sent to Sentry
+// as fatal event, see observance.Obs.LogPanic. The client receives a 500 response via the HTTPErrorHandler.
+// http.ErrAbortHandler is not recovered so the server can abort the response as intended.
+func Recover(obs *observance.Obs) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				r := recover()
+				if r == nil {
+					return
+				}
+				if r == http.ErrAbortHandler {
+					panic(r)
+				}
+
+				obs.CopyWithRequest(c.Request()).LogPanic(r, debug.Stack())
+				// An HTTPError is returned so the panic is not logged a second time.
+				err = httperrors.New(http.StatusInternalServerError, nil)
+			}()
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestRecover(t *testing.T) {
+	testLogger := observance.NewTestLogger()
+	obs := &observance.Obs{Logger: testLogger}
+	e, _, err := NewWithOptions(obs)
+	require.NoError(t, err)
+	e.GET("/panic", func(c echo.Context) error {
+		panic("testPanic")
+	})
+	e.GET("/panic-error", func(c echo.Context) error {
+		panic(errors.New("testError"))
+	})
+	e.GET("/abort", func(c echo.Context) error {
+		panic(http.ErrAbortHandler)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		testLogger.Reset()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, `{"message":"Internal Server Error"}`, strings.TrimSpace(rec.Body.String()))
+
+		entries := testLogger.Entries()
+		require.Len(t, entries, 1, "the panic is only logged once")
+		assert.Equal(t, "error", entries[0].Level)
+		assert.Equal(t, "testPanic", entries[0].Message)
+		assert.Equal(t, true, entries[0].Data[observance.PanicKey])
+		assert.Equal(t, "/panic", entries[0].Data["url"])
+		assert.Equal(t, http.MethodGet, entries[0].Data["method"])
+		assert.Contains(t, entries[0].Data["stack"], "recover_test.go")
+	})
+
+	t.Run("error", func(t *testing.T) {
+		testLogger.Reset()
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic-error", nil))
+
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "testError", testLogger.LastEntry().Message)
+		assert.EqualError(t, testLogger.LastEntry().Data["error"].(error), "testError")
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+		})
+	})
+}
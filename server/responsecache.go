@@ -0,0 +1,331 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+)
+
+const (
+	// HeaderResponseCache is set to "HIT" if the response was served from the cache and to "MISS" otherwise.
+	HeaderResponseCache = "X-Cache"
+
+	defaultResponseCacheTTL = time.Minute
+
+	// Not all headers used here are defined by echo.
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+	headerAge         = "Age"
+)
+
+// ResponseCacheConfig configures the response cache.
+type ResponseCacheConfig struct {
+	// Cache stores the responses and the versions of the tags. The prefix of the cache client is applied to all keys.
+	Cache cache.Cache
+	// TTL defines how long responses are stored if the route does not define it. The default is one minute.
+	TTL time.Duration
+	// VaryHeaders are the request headers that lead to different responses, e.g. Accept-Language.
+	// They are added to the key and to the Vary header of the response.
+	VaryHeaders []string
+	// Scope separates the responses of different clients, e.g. KeyByContext for the subject of an authenticated user.
+	// By default the responses are shared by all clients, so routes with user specific responses must not be cached.
+	Scope func(c echo.Context) string
+}
+
+// ResponseCache stores GET responses in the cache so they can be served without calling the handler.
+// The routes that should be cached are defined via Middleware, the cached responses can be invalidated via Invalidate.
+type ResponseCache struct {
+	config ResponseCacheConfig
+}
+
+// cachedResponse is the response that is stored in the cache.
+type cachedResponse struct {
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"storedAt"`
+}
+
+// NewResponseCache creates a response cache.
+func NewResponseCache(config ResponseCacheConfig) *ResponseCache {
+	if config.TTL == 0 {
+		config.TTL = defaultResponseCacheTTL
+	}
+	for i, header := range config.VaryHeaders {
+		config.VaryHeaders[i] = http.CanonicalHeaderKey(header)
+	}
+	return &ResponseCache{config: config}
+}
+
+// Middleware returns a middleware that caches the 200 responses of the route for the given TTL (0 means the TTL of
+// the config). The key consists of the path, the query, the vary headers and the scope. All responses get an ETag
+// and requests with a matching If-None-Match header receive a 304 response without body.
+//
+// The tags are used to invalidate the responses, e.g. "invoices" and "invoice:{id}". Placeholders in curly braces
+// are replaced with the path parameters of the request.
+//
+// The Cache-Control headers are respected: Requests with "no-store" bypass the cache, requests with "no-cache" or
+// "max-age=0" are not served from the cache. Responses with "no-store", "no-cache", "private" or Set-Cookie are
+// not stored, "s-maxage" and "max-age" of the response replace the TTL. Errors of the cache are logged and the
+// request is handled without the cache.
+func (rc *ResponseCache) Middleware(ttl time.Duration, tags ...string) echo.MiddlewareFunc {
+	if ttl == 0 {
+		ttl = rc.config.TTL
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestDirectives := parseCacheControl(req.Header.Get(echo.HeaderCacheControl))
+			if req.Method != http.MethodGet || requestDirectives.has("no-store") {
+				return next(c)
+			}
+
+			if len(rc.config.VaryHeaders) > 0 {
+				c.Response().Header().Add(echo.HeaderVary, strings.Join(rc.config.VaryHeaders, ", "))
+			}
+
+			key, err := rc.key(c, tags)
+			if err != nil {
+				c.Logger().Error(fmt.Errorf("failed to create response cache key: %w", err))
+				return serveWithETag(c, next, nil)
+			}
+
+			if !requestDirectives.has("no-cache") && requestDirectives["max-age"] != "0" && rc.serveCached(c, key) {
+				return nil
+			}
+
+			c.Response().Header().Set(HeaderResponseCache, "MISS")
+			return serveWithETag(c, next, func(resp *cachedResponse) {
+				rc.store(c, key, resp, ttl)
+			})
+		}
+	}
+}
+
+// Invalidate removes the responses with the given tags from the cache. Placeholders are not supported here,
+// the path parameters need to be filled in, e.g. "invoice:42". The responses are not deleted, the version
+// of the tags is increased instead so the keys change and the old entries expire.
+func (rc *ResponseCache) Invalidate(tags ...string) error {
+	for _, tag := range tags {
+		if _, err := rc.config.Cache.Incr(tagVersionKey(tag)); err != nil {
+			return fmt.Errorf("failed to invalidate tag %q: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+func tagVersionKey(tag string) string {
+	return "responsecache:tag:" + tag
+}
+
+// key hashes everything that identifies the response, including the current versions of the tags.
+func (rc *ResponseCache) key(c echo.Context, tags []string) (string, error) {
+	req := c.Request()
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n", req.URL.Path, req.URL.Query().Encode())
+	for _, header := range rc.config.VaryHeaders {
+		fmt.Fprintf(hash, "%s: %s\n", header, strings.Join(req.Header.Values(header), ", "))
+	}
+	if rc.config.Scope != nil {
+		fmt.Fprintf(hash, "scope: %s\n", rc.config.Scope(c))
+	}
+
+	for _, tag := range tags {
+		tag = replacePathParams(c, tag)
+		version, err := rc.config.Cache.GetInt(tagVersionKey(tag))
+		if err != nil && !errors.Is(err, cache.ErrNotFound) {
+			return "", err
+		}
+		fmt.Fprintf(hash, "tag: %s=%d\n", tag, version)
+	}
+
+	return "responsecache:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// replacePathParams replaces placeholders like "{id}" with the values of the path parameters.
+func replacePathParams(c echo.Context, tag string) string {
+	for _, name := range c.ParamNames() {
+		tag = strings.ReplaceAll(tag, "{"+name+"}", c.Param(name))
+	}
+	return tag
+}
+
+// serveCached sends the cached response if there is one. It returns true if a response was sent.
+func (rc *ResponseCache) serveCached(c echo.Context, key string) bool {
+	cached := cachedResponse{}
+	err := rc.config.Cache.GetJSON(key, &cached)
+	if errors.Is(err, cache.ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		c.Logger().Error(fmt.Errorf("failed to load cached response: %w", err))
+		return false
+	}
+
+	header := c.Response().Header()
+	for name, values := range cached.Header {
+		header[name] = values
+	}
+	header.Set(HeaderResponseCache, "HIT")
+	header.Set(headerAge, strconv.Itoa(int(time.Since(cached.StoredAt).Seconds())))
+	if err := writeWithETag(c, http.StatusOK, cached.Body); err != nil {
+		c.Logger().Error(fmt.Errorf("failed to send cached response: %w", err))
+	}
+	return true
+}
+
+func (rc *ResponseCache) store(c echo.Context, key string, resp *cachedResponse, ttl time.Duration) {
+	directives := parseCacheControl(resp.Header.Get(echo.HeaderCacheControl))
+	if directives.has("no-store") || directives.has("no-cache") || directives.has("private") ||
+		resp.Header.Get("Set-Cookie") != "" {
+		return
+	}
+	if maxAge, ok := directives.maxAge(); ok {
+		ttl = maxAge
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	if err := rc.config.Cache.SetJSON(key, resp, ttl); err != nil {
+		c.Logger().Error(fmt.Errorf("failed to store response in cache: %w", err))
+	}
+}
+
+// serveWithETag calls the handler with a buffered response so the ETag can be set before the response is sent.
+// The store function is called with the headers set by the handler and the body if the status is 200.
+func serveWithETag(c echo.Context, next echo.HandlerFunc, store func(resp *cachedResponse)) error {
+	res := c.Response()
+	headerBefore := res.Header().Clone()
+	buffer := &bufferedResponse{ResponseWriter: res.Writer}
+	res.Writer = buffer
+
+	handlerErr := next(c)
+	res.Writer = buffer.ResponseWriter
+	if !res.Committed {
+		return handlerErr
+	}
+	if handlerErr != nil || buffer.status != http.StatusOK {
+		res.Writer.WriteHeader(buffer.status)
+		_, _ = res.Writer.Write(buffer.body.Bytes())
+		return handlerErr
+	}
+
+	body := buffer.body.Bytes()
+	if store != nil {
+		store(&cachedResponse{Header: changedHeaders(headerBefore, res.Header()), Body: body, StoredAt: time.Now()})
+	}
+	// The response was already marked as committed, the status is written directly.
+	return writeETagResponse(res.Writer, c.Request(), body)
+}
+
+// writeWithETag sends the body with its ETag via the echo response.
+func writeWithETag(c echo.Context, status int, body []byte) error {
+	res := c.Response()
+	res.Status = status
+	res.Committed = true
+	return writeETagResponse(res.Writer, c.Request(), body)
+}
+
+// writeETagResponse sets the ETag and sends either the body or a 304 response if the If-None-Match header matches.
+func writeETagResponse(w http.ResponseWriter, req *http.Request, body []byte) error {
+	etag := w.Header().Get(headerETag)
+	if etag == "" {
+		sum := sha256.Sum256(body)
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		w.Header().Set(headerETag, etag)
+	}
+
+	if etagMatches(req.Header.Get(headerIfNoneMatch), etag) {
+		w.Header().Del(echo.HeaderContentLength)
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(body)
+	return err
+}
+
+// etagMatches implements the weak comparison used for If-None-Match, see RFC 7232.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// changedHeaders returns the headers that were set by the handler. Headers set by other middleware before,
+// e.g. RateLimit-Remaining, are specific to the request and are not stored.
+func changedHeaders(before http.Header, after http.Header) http.Header {
+	changed := http.Header{}
+	for name, values := range after {
+		if name == HeaderResponseCache || strings.Join(before[name], "\n") == strings.Join(values, "\n") {
+			continue
+		}
+		changed[name] = values
+	}
+	return changed
+}
+
+// bufferedResponse keeps the response in memory until the handler is finished.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
+
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// cacheControl contains the directives of a Cache-Control header, names are lower case.
+type cacheControl map[string]string
+
+func parseCacheControl(header string) cacheControl {
+	directives := cacheControl{}
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name != "" {
+			directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+	}
+	return directives
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// maxAge returns the time shared caches may store the response, s-maxage takes precedence over max-age.
+func (cc cacheControl) maxAge() (time.Duration, bool) {
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if value, ok := cc[name]; ok {
+			seconds, err := strconv.Atoi(value)
+			if err == nil {
+				return time.Duration(seconds) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
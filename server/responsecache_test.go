@@ -0,0 +1,198 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/cache"
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestResponseCache(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err, "error in test setup")
+	defer redisServer.Close()
+	client, err := cache.NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
+	require.NoError(t, err, "error in test setup")
+
+	e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+
+	calls := 0
+	responseCache := NewResponseCache(ResponseCacheConfig{Cache: client, VaryHeaders: []string{"accept-language"}})
+	e.GET("/invoices/:id", func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set("X-Invoice", c.Param("id"))
+		if cacheControl := c.QueryParam("cacheControl"); cacheControl != "" {
+			c.Response().Header().Set(echo.HeaderCacheControl, cacheControl)
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"id": c.Param("id"), "call": calls, "lang": c.Request().Header.Get("Accept-Language")})
+	}, responseCache.Middleware(time.Hour, "invoices", "invoice:{id}"))
+	e.GET("/failing", func(c echo.Context) error {
+		calls++
+		if c.QueryParam("fail") == "error" {
+			return errors.New("testError")
+		}
+		return c.String(http.StatusNotFound, "not found")
+	}, responseCache.Middleware(0))
+	e.GET("/rate-limited", func(c echo.Context) error {
+		calls++
+		return c.String(http.StatusOK, "ok")
+	}, RateLimit(RateLimitConfig{Limiter: NewMemoryRateLimiter(Rate{Limit: 10, Period: time.Minute})}), responseCache.Middleware(0))
+
+	call := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("hit and miss", func(t *testing.T) {
+		first := call("/invoices/1?b=2&a=1", nil)
+		assert.Equal(t, http.StatusOK, first.Code)
+		assert.Equal(t, "MISS", first.Header().Get(HeaderResponseCache))
+		assert.Equal(t, "Accept-Language", first.Header().Get(echo.HeaderVary))
+		assert.NotEmpty(t, first.Header().Get(headerETag))
+		assert.JSONEq(t, `{"id":"1","call":1,"lang":""}`, first.Body.String())
+
+		second := call("/invoices/1?a=1&b=2", nil)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "HIT", second.Header().Get(HeaderResponseCache))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, first.Header().Get(headerETag), second.Header().Get(headerETag))
+		assert.Equal(t, "1", second.Header().Get("X-Invoice"))
+		assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, second.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "0", second.Header().Get(headerAge))
+		assert.Equal(t, 1, calls)
+
+		assert.Equal(t, "MISS", call("/invoices/2", nil).Header().Get(HeaderResponseCache), "the path is part of the key")
+		assert.Equal(t, "MISS", call("/invoices/1?a=1", nil).Header().Get(HeaderResponseCache), "the query is part of the key")
+		assert.Equal(t, "MISS", call("/invoices/1?a=1&b=2", map[string]string{"Accept-Language": "de"}).Header().Get(HeaderResponseCache), "vary headers are part of the key")
+		assert.Equal(t, "HIT", call("/invoices/1?a=1&b=2", map[string]string{"Accept-Language": "de"}).Header().Get(HeaderResponseCache))
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("etag", func(t *testing.T) {
+		miss := call("/invoices/3", nil)
+		etag := miss.Header().Get(headerETag)
+
+		notModified := call("/invoices/3", map[string]string{headerIfNoneMatch: `"other", W/` + etag})
+		assert.Equal(t, http.StatusNotModified, notModified.Code)
+		assert.Empty(t, notModified.Body.String())
+		assert.Equal(t, etag, notModified.Header().Get(headerETag))
+
+		assert.Equal(t, http.StatusOK, call("/invoices/3", map[string]string{headerIfNoneMatch: `"other"`}).Code)
+
+		notModified = call("/invoices/4", map[string]string{headerIfNoneMatch: "*"})
+		assert.Equal(t, http.StatusNotModified, notModified.Code, "the ETag is also checked on a miss")
+		assert.Equal(t, "MISS", notModified.Header().Get(HeaderResponseCache))
+		assert.Equal(t, "HIT", call("/invoices/4", nil).Header().Get(HeaderResponseCache), "the response is stored on a 304")
+	})
+
+	t.Run("invalidation", func(t *testing.T) {
+		call("/invoices/5", nil)
+		call("/invoices/6", nil)
+		require.Equal(t, "HIT", call("/invoices/5", nil).Header().Get(HeaderResponseCache))
+
+		require.NoError(t, responseCache.Invalidate("invoice:5"))
+		assert.Equal(t, "MISS", call("/invoices/5", nil).Header().Get(HeaderResponseCache))
+		assert.Equal(t, "HIT", call("/invoices/6", nil).Header().Get(HeaderResponseCache), "other tags are not affected")
+
+		require.NoError(t, responseCache.Invalidate("invoices"))
+		assert.Equal(t, "MISS", call("/invoices/5", nil).Header().Get(HeaderResponseCache))
+		assert.Equal(t, "MISS", call("/invoices/6", nil).Header().Get(HeaderResponseCache))
+	})
+
+	t.Run("cache control", func(t *testing.T) {
+		call("/invoices/7", nil)
+		before := calls
+
+		rec := call("/invoices/7", map[string]string{echo.HeaderCacheControl: "no-cache"})
+		assert.Equal(t, "MISS", rec.Header().Get(HeaderResponseCache))
+		rec = call("/invoices/7", map[string]string{echo.HeaderCacheControl: "max-age=0"})
+		assert.Equal(t, "MISS", rec.Header().Get(HeaderResponseCache))
+		rec = call("/invoices/7", map[string]string{echo.HeaderCacheControl: "no-store"})
+		assert.Empty(t, rec.Header().Get(HeaderResponseCache))
+		assert.Empty(t, rec.Header().Get(headerETag), "the middleware is bypassed")
+		assert.Equal(t, before+3, calls)
+
+		for _, cacheControl := range []string{"no-store", "private", "max-age=0"} {
+			call("/invoices/8?cacheControl="+cacheControl, nil)
+			rec = call("/invoices/8?cacheControl="+cacheControl, nil)
+			assert.Equal(t, "MISS", rec.Header().Get(HeaderResponseCache), cacheControl)
+		}
+
+		call("/invoices/9?cacheControl=max-age=60", nil)
+		assert.Equal(t, "HIT", call("/invoices/9?cacheControl=max-age=60", nil).Header().Get(HeaderResponseCache))
+		redisServer.FastForward(2 * time.Minute)
+		assert.Equal(t, "MISS", call("/invoices/9?cacheControl=max-age=60", nil).Header().Get(HeaderResponseCache), "max-age replaces the TTL")
+	})
+
+	t.Run("not stored", func(t *testing.T) {
+		before := calls
+		rec := call("/failing", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "not found", rec.Body.String())
+		assert.Equal(t, "MISS", call("/failing", nil).Header().Get(HeaderResponseCache))
+
+		rec = call("/failing?fail=error", nil)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.Equal(t, "MISS", call("/failing?fail=error", nil).Header().Get(HeaderResponseCache))
+		assert.Equal(t, before+4, calls)
+	})
+
+	t.Run("headers of other middleware", func(t *testing.T) {
+		call("/rate-limited", nil)
+		rec := call("/rate-limited", nil)
+		assert.Equal(t, "HIT", rec.Header().Get(HeaderResponseCache))
+		assert.Equal(t, "8", rec.Header().Get(HeaderRateLimitRemaining), "the stored response does not overwrite the header")
+	})
+
+	t.Run("cache error", func(t *testing.T) {
+		redisServer.Close()
+		defer func() {
+			require.NoError(t, redisServer.Restart())
+		}()
+
+		before := calls
+		rec := call("/invoices/10", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get(headerETag))
+		assert.Equal(t, before+1, calls)
+		assert.Error(t, responseCache.Invalidate("invoices"))
+	})
+}
+
+func TestResponseCacheScope(t *testing.T) {
+	redisServer, err := miniredis.Run()
+	require.NoError(t, err, "error in test setup")
+	defer redisServer.Close()
+	client, err := cache.NewRedis(redisServer.Host(), redisServer.Port(), "testPrefix")
+	require.NoError(t, err, "error in test setup")
+
+	e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()})
+	require.NoError(t, err)
+	responseCache := NewResponseCache(ResponseCacheConfig{Cache: client, Scope: KeyByHeader("X-User")})
+	e.GET("/me", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Request().Header.Get("X-User"))
+	}, responseCache.Middleware(0))
+
+	for _, user := range []string{"a", "b", "a"} {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, user, rec.Body.String())
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	goValidator "github.com/go-playground/validator/v10"
+)
+
+// Tags of the rules that are registered in addition to the ones provided by go-playground/validator.
+// BIC codes can be validated with the "bic" rule of the validator package.
+const (
+	// RuleIBAN checks the length for the country, the format and the check digits of an IBAN.
+	// Spaces and lowercase letters are allowed.
+	RuleIBAN = "iban"
+	// RuleVATID checks the format of a VAT identification number of an EU member state, e.g. "DE123456789".
+	RuleVATID = "vat_id"
+	// RuleCurrency checks for an ISO 4217 currency code, e.g. "EUR".
+	RuleCurrency = "currency"
+	// RuleCountry checks for an ISO 3166-1 alpha-2 country code, e.g. "DE".
+	RuleCountry = "country"
+)
+
+var ibanRegex = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+
+// ibanLengths contains the length of the IBANs per country according to the IBAN registry of SWIFT.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16, "BG": 22, "BH": 22, "BI": 27,
+	"BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28, "CZ": 24, "DE": 22, "DJ": 27, "DK": 18, "DO": 28,
+	"EE": 20, "EG": 29, "ES": 24, "FI": 18, "FK": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23,
+	"GL": 18, "GR": 27, "GT": 28, "HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23, "IS": 26, "IT": 27,
+	"JO": 30, "KW": 30, "KZ": 20, "LB": 28, "LC": 32, "LI": 21, "LT": 20, "LU": 20, "LV": 21, "LY": 25,
+	"MC": 27, "MD": 24, "ME": 22, "MK": 19, "MN": 20, "MR": 27, "MT": 31, "MU": 30, "NI": 28, "NL": 18,
+	"NO": 15, "OM": 23, "PK": 24, "PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "RU": 33,
+	"SA": 24, "SC": 31, "SD": 18, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "SO": 23, "ST": 25, "SV": 28,
+	"TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20, "YE": 30,
+}
+
+// vatIDRegexes contains the formats of the VAT identification numbers without the country prefix.
+var vatIDRegexes = map[string]*regexp.Regexp{
+	"AT": regexp.MustCompile(`^U[0-9]{8}$`),
+	"BE": regexp.MustCompile(`^[01][0-9]{9}$`),
+	"BG": regexp.MustCompile(`^[0-9]{9,10}$`),
+	"CY": regexp.MustCompile(`^[0-9]{8}[A-Z]$`),
+	"CZ": regexp.MustCompile(`^[0-9]{8,10}$`),
+	"DE": regexp.MustCompile(`^[0-9]{9}$`),
+	"DK": regexp.MustCompile(`^[0-9]{8}$`),
+	"EE": regexp.MustCompile(`^[0-9]{9}$`),
+	"EL": regexp.MustCompile(`^[0-9]{9}$`),
+	"ES": regexp.MustCompile(`^[A-Z0-9][0-9]{7}[A-Z0-9]$`),
+	"FI": regexp.MustCompile(`^[0-9]{8}$`),
+	"FR": regexp.MustCompile(`^[A-HJ-NP-Z0-9]{2}[0-9]{9}$`),
+	"HR": regexp.MustCompile(`^[0-9]{11}$`),
+	"HU": regexp.MustCompile(`^[0-9]{8}$`),
+	"IE": regexp.MustCompile(`^([0-9]{7}[A-W][A-IW]?|[0-9][A-Z+*][0-9]{5}[A-W])$`),
+	"IT": regexp.MustCompile(`^[0-9]{11}$`),
+	"LT": regexp.MustCompile(`^([0-9]{9}|[0-9]{12})$`),
+	"LU": regexp.MustCompile(`^[0-9]{8}$`),
+	"LV": regexp.MustCompile(`^[0-9]{11}$`),
+	"MT": regexp.MustCompile(`^[0-9]{8}$`),
+	"NL": regexp.MustCompile(`^[0-9]{9}B[0-9]{2}$`),
+	"PL": regexp.MustCompile(`^[0-9]{10}$`),
+	"PT": regexp.MustCompile(`^[0-9]{9}$`),
+	"RO": regexp.MustCompile(`^[0-9]{2,10}$`),
+	"SE": regexp.MustCompile(`^[0-9]{12}$`),
+	"SI": regexp.MustCompile(`^[0-9]{8}$`),
+	"SK": regexp.MustCompile(`^[0-9]{10}$`),
+	"XI": regexp.MustCompile(`^([0-9]{9}|[0-9]{12}|GD[0-9]{3}|HA[0-9]{3})$`),
+}
+
+// registerDefaultRules adds the rules the validator package does not provide (under the needed name).
+func registerDefaultRules(validator *goValidator.Validate) {
+	rules := map[string]goValidator.Func{
+		RuleIBAN:     isIBAN,
+		RuleVATID:    isVATID,
+		RuleCurrency: aliasRule(validator, "iso4217"),
+		RuleCountry:  aliasRule(validator, "iso3166_1_alpha2"),
+	}
+	for tag, fn := range rules {
+		// Registering only fails for empty tags or nil functions.
+		_ = validator.RegisterValidation(tag, fn)
+	}
+}
+
+// aliasRule runs an existing rule of the validator, this way the rule gets a name that is easier to remember.
+func aliasRule(validator *goValidator.Validate, tag string) goValidator.Func {
+	return func(fl goValidator.FieldLevel) bool {
+		return validator.Var(fl.Field().Interface(), tag) == nil
+	}
+}
+
+func isIBAN(fl goValidator.FieldLevel) bool {
+	iban := strings.ToUpper(strings.ReplaceAll(fl.Field().String(), " ", ""))
+	if !ibanRegex.MatchString(iban) || len(iban) != ibanLengths[iban[:2]] {
+		return false
+	}
+
+	// Move the country code and check digits to the end, convert letters to numbers (A=10, ..., Z=35)
+	// and calculate the remainder step by step so the number does not overflow.
+	rearranged := iban[4:] + iban[:4]
+	remainder := 0
+	for _, r := range rearranged {
+		if r >= 'A' && r <= 'Z' {
+			remainder = (remainder*100 + int(r-'A'+10)) % 97
+		} else {
+			remainder = (remainder*10 + int(r-'0')) % 97
+		}
+	}
+	return remainder == 1
+}
+
+func isVATID(fl goValidator.FieldLevel) bool {
+	vatID := strings.ReplaceAll(fl.Field().String(), " ", "")
+	if len(vatID) < 4 {
+		return false
+	}
+
+	regex, ok := vatIDRegexes[vatID[:2]]
+	return ok && regex.MatchString(vatID[2:])
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,72 +12,205 @@ import (
 	"time"
 
 	"github.com/fastbill/go-httperrors/v2"
+	goValidator "github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"gorm.io/gorm"
 
 	"github.com/fastbill/go-service-toolkit/v4/observance"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
 )
 
 const defaultTimeout = 30 * time.Second
 
-var defaultBinder = echo.DefaultBinder{}
-
 // New creates an echo server instance with the given logger, CORS middleware if CORSOrigins was supplied
 // and optionally a timeout setting that is applied for read and write.
+// It is kept for compatibility, NewWithOptions allows to configure more settings.
 func New(obs *observance.Obs, CORSOrigins string, timeout ...string) (*echo.Echo, chan struct{}, error) {
-	timeoutDuration := defaultTimeout
+	// The limits of NewWithOptions are not applied to keep the behavior of existing services.
+	opts := []Option{WithBodyLimit(0), WithHandlerTimeout(0)}
 	if len(timeout) > 0 {
 		parsedTimeout, err := time.ParseDuration(timeout[0])
 		if err != nil {
 			return nil, nil, fmt.Errorf("timeout could not be parsed: %w", err)
 		}
-		timeoutDuration = parsedTimeout
+		opts = append(opts, WithTimeout(parsedTimeout))
 	}
 
-	echoServer := echo.New()
+	if CORSOrigins != "" {
+		opts = append(opts, WithCORS(strings.Split(CORSOrigins, ",")...))
+	}
 
-	// Configure Echo.
-	echoServer.HideBanner = true
-	echoServer.HidePort = true
+	return NewWithOptions(obs, opts...)
+}
+
+// NewWithOptions creates an echo server instance with the given logger and options.
+// Without options the server uses a timeout of 30 seconds, a graceful shutdown timeout of 9 seconds,
+// HTTP/2 is disabled and the middleware for removing trailing slashes, security headers and panic recovery is applied.
+// Request bodies are limited to DefaultBodyLimit and the context of each request gets a deadline matching the timeout,
+// see WithBodyLimit and WithHandlerTimeout.
+// The returned channel is closed when the graceful shutdown is completed.
+func NewWithOptions(obs *observance.Obs, opts ...Option) (_ *echo.Echo, _ chan struct{}, err error) {
+	cfg := newConfig(opts)
+	defer func() {
+		// Without the main server nothing would ever shut down the admin server.
+		if err != nil && cfg.admin != nil {
+			_ = cfg.admin.shutdown(context.Background())
+		}
+	}()
 
-	echoServer.Server.ReadTimeout = timeoutDuration
-	echoServer.Server.WriteTimeout = timeoutDuration
-	echoServer.Server.ReadHeaderTimeout = timeoutDuration
-	defaultIdleTimeout := 120 * time.Second
-	if defaultIdleTimeout > timeoutDuration {
+	echoServer := newEcho(obs, cfg.errorResponses)
+	echoServer.Binder = NewBinder(cfg.binder)
+	echoServer.Server.ReadTimeout = cfg.timeout
+	echoServer.Server.WriteTimeout = cfg.timeout
+	echoServer.Server.ReadHeaderTimeout = cfg.timeout
+	// If the idle timeout is not set, the value of ReadTimeout would be used.
+	// See https://pkg.go.dev/net/http#Server
+	echoServer.Server.IdleTimeout = cfg.idleTimeout
+	if cfg.idleTimeout == 0 && defaultIdleTimeout > cfg.timeout {
 		echoServer.Server.IdleTimeout = defaultIdleTimeout
 	}
-	// By default, the value of ReadTimeout is used.
-	// See https://pkg.go.dev/net/http#Server
 
-	echoServer.HTTPErrorHandler = HTTPErrorHandler(obs)
-	echoServer.Binder = &bindValidator{}
+	echoServer.DisableHTTP2 = !cfg.http2
+	if cfg.tlsCertFile != "" {
+		reloader, err := NewCertReloader(cfg.tlsCertFile, cfg.tlsKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		reloader.onError = func(err error) {
+			obs.Logger.WithError(err).Error("failed to reload TLS certificate, the previous certificate is used")
+		}
+		echoServer.Server.TLSConfig = newTLSConfig(reloader, cfg.http2)
+	}
+	if cfg.h2c {
+		if err := configureH2C(echoServer); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := applyMiddleware(echoServer, obs, cfg); err != nil {
+		return nil, nil, err
+	}
+	registerOperationalEndpoints(echoServer, cfg)
+
+	var connsClosed chan struct{}
+	if cfg.shutdownManager != nil {
+		connsClosed = registerShutdownHook(echoServer, cfg)
+	} else {
+		connsClosed = setupGracefulShutdown(echoServer, obs, cfg)
+	}
+
+	return echoServer, connsClosed, nil
+}
+
+// newEcho creates an echo server with the error handler, binder, validator and logger of the toolkit.
+func newEcho(obs *observance.Obs, errorResponses ErrorResponseConfig) *echo.Echo {
+	echoServer := echo.New()
+	echoServer.HideBanner = true
+	echoServer.HidePort = true
+	echoServer.HTTPErrorHandler = HTTPErrorHandlerWithConfig(obs, errorResponses)
+	echoServer.Binder = NewBinder(BinderConfig{})
 	echoServer.Validator = NewValidator()
-	echoServer.Logger = Logger{obs.Logger}
-	echoServer.DisableHTTP2 = true
+	echoServer.Logger = NewLogger(obs.Logger)
+	return echoServer
+}
 
-	echoServer.Pre(middleware.RemoveTrailingSlash())
-	echoServer.Use(middleware.Secure())
-	echoServer.Use(middleware.Recover())
+// registerOperationalEndpoints registers the health endpoints on the admin server if there is one
+// and remembers the admin server so it is started by Start.
+func registerOperationalEndpoints(echoServer *echo.Echo, cfg *config) {
+	if cfg.admin == nil {
+		if cfg.health != nil {
+			cfg.health.Register(echoServer)
+		}
+		return
+	}
 
-	if CORSOrigins != "" {
-		origins := strings.Split(CORSOrigins, ",")
+	if cfg.health != nil {
+		cfg.health.Register(cfg.admin.Echo)
+	}
+	adminServers.Store(echoServer, cfg.admin)
+}
+
+// shutdownServers shuts down the main server first, so the admin server still serves the readiness
+// endpoint and metrics while the ongoing requests are finished.
+func shutdownServers(ctx context.Context, echoServer *echo.Echo, cfg *config) error {
+	err := echoServer.Shutdown(ctx)
+	if cfg.admin == nil {
+		return err
+	}
+	adminServers.Delete(echoServer)
+	if adminErr := cfg.admin.shutdown(ctx); adminErr != nil && err == nil {
+		err = fmt.Errorf("failed to shut down admin server: %w", adminErr)
+	}
+	return err
+}
+
+// applyMiddleware adds the default middleware that was not disabled and the middleware configured via the options.
+func applyMiddleware(echoServer *echo.Echo, obs *observance.Obs, cfg *config) error {
+	if !cfg.disabledMiddleware[MiddlewareRemoveTrailingSlash] {
+		echoServer.Pre(middleware.RemoveTrailingSlash())
+	}
+	echoServer.Use(provideObs(obs))
+	if !cfg.disabledMiddleware[MiddlewareSecure] {
+		echoServer.Use(middleware.Secure())
+	}
+	if !cfg.disabledMiddleware[MiddlewareRecover] {
+		echoServer.Use(Recover(obs))
+	}
+	if cfg.bodyLimit > 0 {
+		echoServer.Use(BodyLimit(cfg.bodyLimit))
+	}
+	if cfg.handlerTimeout > 0 {
+		echoServer.Use(Timeout(cfg.handlerTimeout))
+	}
+
+	if len(cfg.corsOrigins) > 0 {
 		echoServer.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-			AllowOrigins: origins,
+			AllowOrigins: cfg.corsOrigins,
 		}))
 	}
+	if cfg.cors != nil {
+		cors, err := CORS(*cfg.cors)
+		if err != nil {
+			return err
+		}
+		echoServer.Use(cors)
+	}
+	return nil
+}
+
+// registerShutdownHook lets the shutdown manager shut down the server.
+// The returned channel is closed when the server was shut down.
+func registerShutdownHook(echoServer *echo.Echo, cfg *config) chan struct{} {
+	connsClosed := make(chan struct{})
+	if cfg.health != nil {
+		cfg.shutdownManager.OnShutdownStart(cfg.health.SetShuttingDown)
+	}
+	cfg.shutdownManager.Register("server", shutdown.OrderServer, func(ctx context.Context) error {
+		defer close(connsClosed)
+		c, cancel := context.WithTimeout(ctx, cfg.shutdownTimeout)
+		defer cancel()
+		return shutdownServers(c, echoServer, cfg)
+	})
+	return connsClosed
+}
 
-	// Set up graceful shutdown.
+// setupGracefulShutdown shuts down the server when SIGINT or SIGTERM is received.
+// The returned channel is closed when the shutdown is completed.
+func setupGracefulShutdown(echoServer *echo.Echo, obs *observance.Obs, cfg *config) chan struct{} {
 	connsClosed := make(chan struct{})
 	sc := make(chan os.Signal, 1)
 	go func() {
 		s := <-sc
 		obs.Logger.WithField("signal", s).Warn("shutting down gracefully")
+		if cfg.health != nil {
+			cfg.health.SetShuttingDown()
+		}
 
-		c, cancel := context.WithTimeout(context.Background(), 9*time.Second)
+		c, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
 		defer cancel()
 
-		err := echoServer.Shutdown(c)
+		err := shutdownServers(c, echoServer, cfg)
 		if err != nil {
 			obs.Logger.Error(err)
 		}
@@ -84,30 +218,56 @@ func New(obs *observance.Obs, CORSOrigins string, timeout ...string) (*echo.Echo
 	}()
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
 
-	return echoServer, connsClosed, nil
+	return connsClosed
 }
 
+// CodedError can be implemented by domain errors to define the status code of the response
+// and a machine readable error code that is added to the body, e.g. {"message": "...", "code": "invoice_locked"}.
+// CodedErrors with a status code of 500 or above are logged.
+type CodedError interface {
+	error
+	StatusCode() int
+	ErrorCode() string
+}
+
+// StatusClientClosedRequest is used when the request context was cancelled, usually because the client
+// closed the connection. The code is not part of the HTTP standard, it was introduced by nginx.
+const StatusClientClosedRequest = 499
+
 // HTTPErrorHandler retruns an error handler that can be used in echo to overwrite the default Echo error handler.
-// It can send responses for echo.HTTPError, htttperrors.HTTPError and standard errors.
-// Standard errors also get logged.
+// It can send responses for echo.HTTPError, htttperrors.HTTPError, CodedError and standard errors, also if they are wrapped.
+// Additionally context.DeadlineExceeded is sent as 504, context.Canceled as 499 and gorm.ErrRecordNotFound as 404.
+// Standard errors, CodedErrors with status 5xx and context.DeadlineExceeded also get logged.
+// The responses are written by the LegacyErrorEncoder, use HTTPErrorHandlerWithConfig for other formats.
 func HTTPErrorHandler(obs *observance.Obs) func(err error, c echo.Context) {
+	return HTTPErrorHandlerWithConfig(obs, ErrorResponseConfig{})
+}
+
+// HTTPErrorHandlerWithConfig works like HTTPErrorHandler but allows to configure the format of the responses.
+func HTTPErrorHandlerWithConfig(obs *observance.Obs, config ErrorResponseConfig) func(err error, c echo.Context) {
+	config = config.withDefaults()
 	return func(err error, c echo.Context) {
-		// Log error if it is not an HTTPError or an Echo error.
-		needsLogging := !isHTTPOrEchoError(err)
-		if needsLogging {
+		// Validation errors are caused by the client, they are sent as 400 response.
+		var validationErrs goValidator.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			err = NewValidationHTTPError(validationErrs)
+		}
+		translateValidationError(err, c)
+
+		handled := handleError(err)
+
+		if handled.log {
 			requestObs := obs.CopyWithRequest(c.Request())
 			requestObs.Logger.Error(err)
 		}
 
-		httpError := buildHTTPError(err)
-
 		// Send response.
 		if !c.Response().Committed {
 			var sendErr error
 			if c.Request().Method == "HEAD" {
-				sendErr = c.NoContent(httpError.StatusCode)
+				sendErr = c.NoContent(handled.httpError.StatusCode)
 			} else {
-				sendErr = httpError.WriteJSON(c.Response())
+				sendErr = writeErrorResponse(c, config, handled)
 			}
 			if sendErr != nil {
 				c.Logger().Error(err)
@@ -116,31 +276,61 @@ func HTTPErrorHandler(obs *observance.Obs) func(err error, c echo.Context) {
 	}
 }
 
-func buildHTTPError(err error) *httperrors.HTTPError {
-	if he, ok := err.(*httperrors.HTTPError); ok {
-		return he
+// handledError describes how an error returned by a handler is sent to the client.
+type handledError struct {
+	httpError *httperrors.HTTPError
+	code      string
+	log       bool
+}
+
+func handleError(err error) handledError {
+	var httpError *httperrors.HTTPError
+	if errors.As(err, &httpError) {
+		return handledError{httpError: httpError}
 	}
 
-	if echoError, ok := err.(*echo.HTTPError); ok {
-		return httperrors.New(echoError.Code, echoError.Message)
+	var echoError *echo.HTTPError
+	if errors.As(err, &echoError) {
+		return handledError{httpError: httperrors.New(echoError.Code, echoError.Message)}
 	}
 
-	return httperrors.New(http.StatusInternalServerError, err)
-}
+	var codedError CodedError
+	if errors.As(err, &codedError) {
+		return handledError{
+			httpError: httperrors.New(codedError.StatusCode(), codedError.Error()),
+			code:      codedError.ErrorCode(),
+			log:       codedError.StatusCode() >= http.StatusInternalServerError,
+		}
+	}
 
-func isHTTPOrEchoError(err error) bool {
-	_, isHTTPError := err.(*httperrors.HTTPError)
-	_, isEchoError := err.(*echo.HTTPError)
-	return isHTTPError || isEchoError
-}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return handledError{httpError: httperrors.New(http.StatusGatewayTimeout, nil), log: true}
+	case errors.Is(err, context.Canceled):
+		return handledError{httpError: httperrors.New(StatusClientClosedRequest, "client closed request")}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return handledError{httpError: httperrors.New(http.StatusNotFound, nil)}
+	}
 
-type bindValidator struct{}
+	return handledError{httpError: httperrors.New(http.StatusInternalServerError, err), log: true}
+}
 
-func (b *bindValidator) Bind(i interface{}, c echo.Context) error {
-	err := defaultBinder.Bind(i, c)
-	if err != nil {
-		return err
+// writeErrorResponse sends the error in the format requested via the Accept header.
+func writeErrorResponse(c echo.Context, config ErrorResponseConfig, handled handledError) error {
+	response := ErrorResponse{
+		Status:    handled.httpError.StatusCode,
+		Message:   handled.httpError.Message,
+		Code:      handled.code,
+		RequestID: config.requestID(c),
+		Request:   c.Request(),
+	}
+	if validationErr, ok := handled.httpError.Message.(*ValidationError); ok {
+		response.Validation = validationErr
 	}
 
-	return c.Validate(i)
+	if len(config.Encoders) > 1 {
+		c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	}
+	encoder := config.selectEncoder(c.Request().Header.Get(echo.HeaderAccept))
+	return encoder.Encode(c.Response(), response)
 }
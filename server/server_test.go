@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+type testDomainError struct {
+	status int
+}
+
+func (e *testDomainError) Error() string {
+	return "invoice is locked"
+}
+
+func (e *testDomainError) StatusCode() int {
+	return e.status
+}
+
+func (e *testDomainError) ErrorCode() string {
+	return "invoice_locked"
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		err          error
+		expectedCode int
+		expectedBody string
+		logged       bool
+	}{
+		{
+			"standard error",
+			errors.New("testError"),
+			http.StatusInternalServerError,
+			`{"message":"testError"}`,
+			true,
+		},
+		{
+			"HTTPError",
+			httperrors.New(http.StatusForbidden, "not allowed"),
+			http.StatusForbidden,
+			`{"message":"not allowed"}`,
+			false,
+		},
+		{
+			"wrapped HTTPError",
+			fmt.Errorf("loading invoice: %w", httperrors.New(http.StatusForbidden, "not allowed")),
+			http.StatusForbidden,
+			`{"message":"not allowed"}`,
+			false,
+		},
+		{
+			"echo error",
+			echo.NewHTTPError(http.StatusUnauthorized, "missing token"),
+			http.StatusUnauthorized,
+			`{"message":"missing token"}`,
+			false,
+		},
+		{
+			"wrapped echo error",
+			fmt.Errorf("auth: %w", echo.ErrUnauthorized),
+			http.StatusUnauthorized,
+			`{"message":"Unauthorized"}`,
+			false,
+		},
+		{
+			"coded error",
+			fmt.Errorf("updating invoice: %w", &testDomainError{status: http.StatusConflict}),
+			http.StatusConflict,
+			`{"message":"invoice is locked","code":"invoice_locked"}`,
+			false,
+		},
+		{
+			"coded server error",
+			&testDomainError{status: http.StatusServiceUnavailable},
+			http.StatusServiceUnavailable,
+			`{"message":"invoice is locked","code":"invoice_locked"}`,
+			true,
+		},
+		{
+			"deadline exceeded",
+			fmt.Errorf("query failed: %w", context.DeadlineExceeded),
+			http.StatusGatewayTimeout,
+			`{"message":"Gateway Timeout"}`,
+			true,
+		},
+		{
+			"canceled",
+			fmt.Errorf("query failed: %w", context.Canceled),
+			StatusClientClosedRequest,
+			`{"message":"client closed request"}`,
+			false,
+		},
+		{
+			"record not found",
+			fmt.Errorf("loading invoice: %w", gorm.ErrRecordNotFound),
+			http.StatusNotFound,
+			`{"message":"Not Found"}`,
+			false,
+		},
+	}
+
+	testLogger := observance.NewTestLogger()
+	handler := HTTPErrorHandler(&observance.Obs{Logger: testLogger})
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			testLogger.Reset()
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			handler(test.err, c)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			assert.Equal(t, test.expectedBody, strings.TrimSpace(rec.Body.String()))
+			assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+			if test.logged {
+				assert.Equal(t, "error", testLogger.LastEntry().Level)
+				assert.Equal(t, test.err.Error(), testLogger.LastEntry().Message)
+			} else {
+				assert.Empty(t, testLogger.Entries())
+			}
+		})
+	}
+
+	t.Run("HEAD request", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodHead, "/", nil), rec)
+
+		handler(gorm.ErrRecordNotFound, c)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+}
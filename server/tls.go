@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+const defaultCertCheckInterval = 10 * time.Second
+
+// CertReloader loads a TLS certificate from files and reloads it when the files change,
+// e.g. when the certificate was renewed by cert-manager. Use GetCertificate in the tls.Config.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	// checkInterval limits how often the modification times of the files are checked.
+	checkInterval time.Duration
+	onError       func(err error)
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewCertReloader loads the certificate and the key from the PEM encoded files.
+// The files are checked for changes at most every 10 seconds.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		checkInterval: defaultCertCheckInterval,
+		onError:       func(err error) {},
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads the certificate from the files.
+func (r *CertReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns the current certificate, it is reloaded first if the files changed.
+// If the reload fails, e.g. because only the certificate was replaced yet, the previous certificate is returned.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	now := time.Now()
+	check := now.Sub(r.checkedAt) >= r.checkInterval
+	if check {
+		r.checkedAt = now
+	}
+	r.mu.Unlock()
+
+	if check {
+		r.reloadIfChanged()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) reloadIfChanged() {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		r.onError(err)
+		return
+	}
+
+	r.mu.Lock()
+	changed := !modTime.Equal(r.modTime)
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	if err := r.Reload(); err != nil {
+		r.onError(err)
+	}
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	latest := time.Time{}
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to check TLS certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// newTLSConfig creates the TLS config of the server. HTTP/2 is offered via ALPN if it is enabled.
+func newTLSConfig(reloader *CertReloader, http2Enabled bool) *tls.Config {
+	nextProtos := []string{"http/1.1"}
+	if http2Enabled {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     nextProtos,
+	}
+}
+
+// Start starts the server on the given address. Unlike echoServer.Start, it serves TLS if WithTLS was used
+// and HTTP/2 without TLS if WithH2C was used. The admin server set via WithAdminServer is started as well,
+// it is shut down again if the server cannot be started.
+func Start(echoServer *echo.Echo, address string) error {
+	admin, hasAdmin := adminServers.Load(echoServer)
+	if hasAdmin {
+		admin.(*AdminServer).serve()
+	}
+
+	err := start(echoServer, address)
+	if hasAdmin && !errors.Is(err, http.ErrServerClosed) {
+		adminServers.Delete(echoServer)
+		_ = admin.(*AdminServer).shutdown(context.Background())
+	}
+	return err
+}
+
+func start(echoServer *echo.Echo, address string) error {
+	if handler, ok := echoServer.Server.Handler.(*h2cHandler); ok && echoServer.Server.TLSConfig == nil {
+		return echoServer.StartH2CServer(address, handler.h2s)
+	}
+	if echoServer.Server.TLSConfig == nil {
+		return echoServer.Start(address)
+	}
+	echoServer.Server.Addr = address
+	return echoServer.StartServer(echoServer.Server)
+}
+
+// h2cHandler serves HTTP/2 without TLS (h2c) via prior knowledge and via the upgrade from HTTP/1.1.
+type h2cHandler struct {
+	http.Handler
+	h2s *http2.Server
+}
+
+// configureH2C sets the h2c handler as handler of the server. The HTTP/2 server is registered with the
+// HTTP server, so the HTTP/2 connections are closed gracefully when the server is shut down.
+func configureH2C(echoServer *echo.Echo) error {
+	h2s := &http2.Server{IdleTimeout: echoServer.Server.IdleTimeout}
+	// ConfigureServer also prepares a TLS config which would make Start serve TLS.
+	tlsConfig := echoServer.Server.TLSConfig
+	if err := http2.ConfigureServer(echoServer.Server, h2s); err != nil {
+		return fmt.Errorf("failed to configure h2c: %w", err)
+	}
+	echoServer.Server.TLSConfig = tlsConfig
+
+	echoServer.Server.Handler = &h2cHandler{Handler: h2c.NewHandler(echoServer, h2s), h2s: h2s}
+	return nil
+}
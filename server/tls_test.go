@@ -0,0 +1,282 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+func TestTLS(t *testing.T) {
+	certFile, keyFile, certPool := writeTestCertificate(t, t.TempDir(), "localhost")
+
+	t.Run("HTTP/1.1", func(t *testing.T) {
+		e := startTestServer(t, WithTLS(certFile, keyFile))
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: certPool},
+			ForceAttemptHTTP2: true,
+		}}
+
+		resp, err := client.Get("https://" + e.TLSListenerAddr().String() + "/proto")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "HTTP/1.1", string(body), "HTTP/2 is disabled by default")
+	})
+
+	t.Run("HTTP/2", func(t *testing.T) {
+		e := startTestServer(t, WithTLS(certFile, keyFile), WithHTTP2())
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: certPool},
+			ForceAttemptHTTP2: true,
+		}}
+
+		resp, err := client.Get("https://" + e.TLSListenerAddr().String() + "/proto")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "HTTP/2.0", string(body))
+	})
+
+	t.Run("invalid files", func(t *testing.T) {
+		_, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()}, WithTLS(keyFile, certFile))
+		assert.Error(t, err)
+		_, _, err = NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()}, WithTLS("missing.pem", keyFile))
+		assert.Error(t, err)
+	})
+}
+
+func TestH2C(t *testing.T) {
+	e := startTestServer(t, WithH2C())
+	url := "http://" + e.ListenerAddr().String() + "/proto"
+
+	t.Run("prior knowledge", func(t *testing.T) {
+		client := &http.Client{Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		}}
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "HTTP/2.0", string(body))
+	})
+
+	t.Run("upgrade", func(t *testing.T) {
+		conn, err := net.Dial("tcp", e.ListenerAddr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte("GET /proto HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAMAAABkAAQCAAAAAAIAAAAA\r\n\r\n"))
+		require.NoError(t, err)
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(t, "h2c", resp.Header.Get("Upgrade"))
+
+		// The response to the upgraded request is sent on stream 1 after the client preface.
+		_, err = conn.Write([]byte(http2.ClientPreface))
+		require.NoError(t, err)
+		framer := http2.NewFramer(conn, reader)
+		require.NoError(t, framer.WriteSettings())
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		for {
+			frame, err := framer.ReadFrame()
+			require.NoError(t, err)
+			if data, ok := frame.(*http2.DataFrame); ok && data.StreamID == 1 {
+				assert.Equal(t, "HTTP/2.0", string(data.Data()))
+				return
+			}
+		}
+	})
+
+	t.Run("failed upgrade", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Upgrade", "h2c")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "HTTP/1.1", string(body), "the request is served via HTTP/1.1 without HTTP2-Settings")
+	})
+
+	t.Run("HTTP/1.1", func(t *testing.T) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, "HTTP/1.1", string(body))
+	})
+}
+
+func TestH2CShutdown(t *testing.T) {
+	e := startTestServer(t, WithH2C())
+	conn, err := net.Dial("tcp", e.ListenerAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(http2.ClientPreface))
+	require.NoError(t, err)
+	framer := http2.NewFramer(conn, conn)
+	require.NoError(t, framer.WriteSettings())
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	// The connection is served via HTTP/2 as soon as the server sent its settings.
+	frame, err := framer.ReadFrame()
+	require.NoError(t, err)
+	require.IsType(t, &http2.SettingsFrame{}, frame)
+
+	go func() {
+		_ = e.Shutdown(context.Background())
+	}()
+	for {
+		frame, err := framer.ReadFrame()
+		require.NoError(t, err, "the connection is closed with GOAWAY")
+		if _, ok := frame.(*http2.GoAwayFrame); ok {
+			return
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeTestCertificate(t, dir, "first")
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	reloader.checkInterval = 0
+	var reloadErr error
+	reloader.onError = func(err error) {
+		reloadErr = err
+	}
+	assert.Equal(t, "first", commonName(t, reloader))
+
+	writeTestCertificate(t, dir, "second")
+	touch(t, time.Now().Add(time.Minute), certFile, keyFile)
+	assert.Equal(t, "second", commonName(t, reloader))
+	assert.NoError(t, reloadErr)
+
+	// Only the certificate was replaced so far, the key does not match.
+	writeTestCertificate(t, dir, "third")
+	previousKey, err := ioutil.ReadFile(filepath.Join(dir, "previous-key.pem"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, previousKey, 0600))
+	touch(t, time.Now().Add(2*time.Minute), certFile, keyFile)
+	assert.Equal(t, "second", commonName(t, reloader), "the previous certificate is used if the reload fails")
+	assert.Error(t, reloadErr)
+
+	reloader.checkInterval = time.Hour
+	writeTestCertificate(t, dir, "fourth")
+	touch(t, time.Now().Add(3*time.Minute), certFile, keyFile)
+	assert.Equal(t, "second", commonName(t, reloader), "the files are not checked before the interval passed")
+}
+
+// startTestServer starts the server on a random port and returns when it accepts connections.
+func startTestServer(t *testing.T, opts ...Option) *echo.Echo {
+	t.Helper()
+	e, _, err := NewWithOptions(&observance.Obs{Logger: observance.NewTestLogger()}, opts...)
+	require.NoError(t, err)
+	e.GET("/proto", func(c echo.Context) error {
+		return c.String(http.StatusOK, c.Request().Proto)
+	})
+
+	started := make(chan error, 1)
+	go func() {
+		started <- Start(e, "127.0.0.1:0")
+	}()
+	t.Cleanup(func() {
+		_ = e.Shutdown(context.Background())
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if e.ListenerAddr() != nil || e.TLSListenerAddr() != nil {
+			return e
+		}
+		select {
+		case err := <-started:
+			require.NoError(t, err, "server could not be started")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	require.Fail(t, "server was not started in time")
+	return nil
+}
+
+// writeTestCertificate creates a self-signed certificate for localhost with the given common name
+// and writes it to cert.pem and key.pem. The previous key is kept in previous-key.pem.
+func writeTestCertificate(t *testing.T, dir string, commonName string) (string, string, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if previous, err := ioutil.ReadFile(keyFile); err == nil {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "previous-key.pem"), previous, 0600))
+	}
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func commonName(t *testing.T, reloader *CertReloader) string {
+	t.Helper()
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+// touch sets the modification time explicitly because the file system might not be precise enough.
+func touch(t *testing.T, modTime time.Time, files ...string) {
+	t.Helper()
+	for _, file := range files {
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used if none of the languages in the Accept-Language header is available.
+const DefaultLocale = "en"
+
+// defaultMessageKey is the key of the message that is used for rules without an own message.
+const defaultMessageKey = "default"
+
+//go:embed locales/*.json
+var defaultLocales embed.FS
+
+// translations maps the locale (e.g. "de" or "de-at") to the message templates by rule.
+type translations map[string]map[string]string
+
+func loadDefaultTranslations() translations {
+	t := translations{}
+	locales, err := fs.Sub(defaultLocales, "locales")
+	if err == nil {
+		err = t.load(locales)
+	}
+	if err != nil {
+		// The files are embedded, this can only happen if they are broken.
+		panic(fmt.Sprintf("failed to load default translations: %s", err))
+	}
+	return t
+}
+
+// load reads all JSON files in the root of fsys. The name of the file without extension is used as locale.
+func (t translations) load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("failed to read locale file %s: %w", file, err)
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(content, &messages); err != nil {
+			return fmt.Errorf("failed to parse locale file %s: %w", file, err)
+		}
+
+		t.add(strings.TrimSuffix(file, path.Ext(file)), messages)
+	}
+
+	return nil
+}
+
+// add merges the messages into the existing ones of the locale.
+func (t translations) add(locale string, messages map[string]string) {
+	locale = normalizeLocale(locale)
+	if t[locale] == nil {
+		t[locale] = map[string]string{}
+	}
+	for rule, message := range messages {
+		t[locale][rule] = message
+	}
+}
+
+// translate sets the message of all field errors and returns the locale that was used.
+func (t translations) translate(details []FieldError, acceptLanguage string) string {
+	locale := t.match(acceptLanguage)
+	messages := t[locale]
+	for i, detail := range details {
+		template, ok := messages[detail.Rule]
+		if !ok {
+			template = messages[defaultMessageKey]
+		}
+		details[i].Message = strings.NewReplacer("{field}", detail.Field, "{param}", detail.Param).Replace(template)
+	}
+	return locale
+}
+
+// match returns the best available locale for the Accept-Language header, e.g. "de-CH;q=0.9, en;q=0.8".
+// If a regional locale like "de-ch" is not available the base language "de" is used.
+func (t translations) match(acceptLanguage string) string {
+	for _, locale := range parseAcceptLanguage(acceptLanguage) {
+		if _, ok := t[locale]; ok {
+			return locale
+		}
+		base := strings.SplitN(locale, "-", 2)[0]
+		if _, ok := t[base]; ok {
+			return base
+		}
+	}
+	return DefaultLocale
+}
+
+// parseAcceptLanguage returns the normalized locales of the header ordered by their quality value.
+func parseAcceptLanguage(header string) []string {
+	locales := []string{}
+	for _, value := range parseQualityValues(header) {
+		if locale := normalizeLocale(value); locale != "*" {
+			locales = append(locales, locale)
+		}
+	}
+	return locales
+}
+
+// parseQualityValues returns the values of headers like Accept or Accept-Language ordered by their quality value,
+// e.g. "de;q=0.8, en" results in ["en", "de"]. Values with a quality of 0 are omitted, parameters are removed.
+func parseQualityValues(header string) []string {
+	type weightedValue struct {
+		value   string
+		quality float64
+	}
+
+	weighted := []weightedValue{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		if value == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			weighted = append(weighted, weightedValue{value: value, quality: quality})
+		}
+	}
+
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].quality > weighted[j].quality
+	})
+
+	values := make([]string, 0, len(weighted))
+	for _, w := range weighted {
+		values = append(values, w.value)
+	}
+	return values
+}
+
+// normalizeLocale turns e.g. "de_AT" into "de-at".
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/fastbill/go-httperrors/v2"
+	goValidator "github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// Messages of the HTTPErrors returned for invalid requests.
+const (
+	msgValidationFailed = "request validation failed"
+	msgBindingFailed    = "request could not be parsed"
+)
+
+// ValidationError is used as message of the HTTPError that is returned if binding or validating a request failed.
+// The response body looks like this:
+//
+//	{"message": {"message": "request validation failed", "details": [{"field": "address.zip", "rule": "len", "param": "5"}]}}
+type ValidationError struct {
+	Message string       `json:"message"`
+	Details []FieldError `json:"details"`
+}
+
+// String lists the invalid fields, it is used when the HTTPError is logged.
+func (v *ValidationError) String() string {
+	fields := make([]string, 0, len(v.Details))
+	for _, detail := range v.Details {
+		fields = append(fields, detail.String())
+	}
+	if len(fields) == 0 {
+		return v.Message
+	}
+	return v.Message + ": " + strings.Join(fields, ", ")
+}
+
+// FieldError describes why the value of a single field is invalid.
+type FieldError struct {
+	// Field is the path of the field in the request using the JSON names, e.g. "address.zip".
+	Field string `json:"field"`
+	// Rule is the validation rule that failed, e.g. "required". If the value had the wrong type, the rule is "type".
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule (e.g. "5" for "len=5") or the expected type for the rule "type".
+	Param string `json:"param,omitempty"`
+	// Message describes the error in the language requested by the client, see Validator.Translate.
+	Message string `json:"message,omitempty"`
+}
+
+// String formats the field error like "address.zip (len=5)".
+func (f FieldError) String() string {
+	if f.Param == "" {
+		return fmt.Sprintf("%s (%s)", f.Field, f.Rule)
+	}
+	return fmt.Sprintf("%s (%s=%s)", f.Field, f.Rule, f.Param)
+}
+
+// NewValidationHTTPError converts errors that occurred during binding or validation of a request into a 400 HTTPError
+// with a ValidationError as message. If the request body was too large, ErrBodyTooLarge is returned.
+// Errors of other types are returned unchanged.
+func NewValidationHTTPError(err error) error {
+	var validationErrs goValidator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return httperrors.New(http.StatusBadRequest, &ValidationError{
+			Message: msgValidationFailed,
+			Details: fieldErrorsFromValidation(validationErrs),
+		})
+	}
+
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+
+	var echoErr *echo.HTTPError
+	if errors.As(err, &echoErr) && echoErr.Code == http.StatusBadRequest {
+		return httperrors.New(http.StatusBadRequest, &ValidationError{
+			Message: msgBindingFailed,
+			Details: fieldErrorsFromBinding(echoErr),
+		})
+	}
+
+	return err
+}
+
+// NewFieldValidationError returns the 400 HTTPError with a ValidationError for validations of a request
+// that are not done by the validator, e.g. against an OpenAPI document.
+func NewFieldValidationError(details []FieldError) error {
+	return httperrors.New(http.StatusBadRequest, &ValidationError{Message: msgValidationFailed, Details: details})
+}
+
+func fieldErrorsFromValidation(validationErrs goValidator.ValidationErrors) []FieldError {
+	details := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		details = append(details, FieldError{
+			Field: fieldPath(fieldErr.Namespace()),
+			Rule:  fieldErr.Tag(),
+			Param: fieldErr.Param(),
+		})
+	}
+	return details
+}
+
+func fieldErrorsFromBinding(err error) []FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{{
+			Field: typeErr.Field,
+			Rule:  ruleType,
+			Param: typeErr.Type.String(),
+		}}
+	}
+	return []FieldError{}
+}
+
+// fieldPath removes the name of the top level struct and of embedded structs from the namespace of the validation
+// error, e.g. "User.address.zip" becomes "address.zip" and "Order.<embedded>.limit" becomes "limit".
+func fieldPath(namespace string) string {
+	segments := strings.Split(namespace, ".")
+	path := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		if i > 0 && segment != embeddedFieldName {
+			path = append(path, segment)
+		}
+	}
+	if len(path) == 0 {
+		return namespace
+	}
+	return strings.Join(path, ".")
+}
+
+// translateValidationError sets the messages of a ValidationError in the language requested by the client
+// if the server uses the Validator of this package. The Content-Language header is set accordingly.
+func translateValidationError(err error, c echo.Context) {
+	var httpErr *httperrors.HTTPError
+	if !errors.As(err, &httpErr) {
+		return
+	}
+	validationErr, ok := httpErr.Message.(*ValidationError)
+	if !ok {
+		return
+	}
+	v, ok := c.Echo().Validator.(*Validator)
+	if !ok {
+		return
+	}
+
+	locale := v.Translate(validationErr.Details, c.Request().Header.Get("Accept-Language"))
+	c.Response().Header().Set("Content-Language", locale)
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fastbill/go-httperrors/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+type testAddress struct {
+	Zip string `json:"zip" validate:"len=5"`
+}
+
+type testUser struct {
+	Name    string      `json:"name" validate:"required"`
+	Age     int         `json:"age" validate:"gte=18"`
+	Address testAddress `json:"address"`
+	Note    string      `validate:"max=3"`
+}
+
+type testEmbedding struct {
+	testAddress
+	*testUser `json:"user"`
+	Billing   testAddress `json:"billing"`
+}
+
+func TestNewValidationHTTPError(t *testing.T) {
+	t.Run("validation errors", func(t *testing.T) {
+		err := NewValidator().Validate(testUser{Age: 10, Address: testAddress{Zip: "123"}, Note: "long"})
+		result := NewValidationHTTPError(err)
+
+		httpErr := &httperrors.HTTPError{}
+		require.ErrorAs(t, result, &httpErr)
+		assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+		assert.Equal(t, &ValidationError{
+			Message: "request validation failed",
+			Details: []FieldError{
+				{Field: "name", Rule: "required"},
+				{Field: "age", Rule: "gte", Param: "18"},
+				{Field: "address.zip", Rule: "len", Param: "5"},
+				{Field: "Note", Rule: "max", Param: "3"},
+			},
+		}, httpErr.Message)
+		assert.Equal(t, "400 - request validation failed: name (required), age (gte=18), address.zip (len=5), Note (max=3)", httpErr.Error())
+	})
+
+	t.Run("embedded structs", func(t *testing.T) {
+		input := testEmbedding{
+			testAddress: testAddress{Zip: "1"},
+			testUser:    &testUser{Name: "Jane", Age: 20, Address: testAddress{Zip: "2"}},
+			Billing:     testAddress{Zip: "3"},
+		}
+		result := NewValidationHTTPError(NewValidator().Validate(input))
+
+		httpErr := &httperrors.HTTPError{}
+		require.ErrorAs(t, result, &httpErr)
+		assert.Equal(t, []FieldError{
+			{Field: "zip", Rule: "len", Param: "5"},
+			{Field: "user.address.zip", Rule: "len", Param: "5"},
+			{Field: "billing.zip", Rule: "len", Param: "5"},
+		}, httpErr.Message.(*ValidationError).Details, "like in JSON, only embedded structs with a name are nested")
+	})
+
+	t.Run("binding error", func(t *testing.T) {
+		err := echo.NewHTTPError(http.StatusBadRequest, "Syntax error")
+		result := NewValidationHTTPError(err)
+
+		httpErr := &httperrors.HTTPError{}
+		require.ErrorAs(t, result, &httpErr)
+		assert.Equal(t, &ValidationError{Message: "request could not be parsed", Details: []FieldError{}}, httpErr.Message)
+	})
+
+	t.Run("other errors are unchanged", func(t *testing.T) {
+		err := errors.New("testError")
+		assert.Equal(t, err, NewValidationHTTPError(err))
+
+		echoErr := echo.NewHTTPError(http.StatusUnsupportedMediaType)
+		assert.Equal(t, echoErr, NewValidationHTTPError(echoErr))
+
+		assert.NoError(t, NewValidationHTTPError(nil))
+	})
+}
+
+func TestNewFieldValidationError(t *testing.T) {
+	err := NewFieldValidationError([]FieldError{{Field: "limit", Rule: "lte", Param: "100"}})
+
+	httpErr := &httperrors.HTTPError{}
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	assert.Equal(t, "400 - request validation failed: limit (lte=100)", httpErr.Error())
+}
+
+func TestBindAndHTTPErrorHandler(t *testing.T) {
+	obs := &observance.Obs{Logger: observance.NewTestLogger()}
+	e, _, err := New(obs, "")
+	require.NoError(t, err)
+	e.POST("/bind", func(c echo.Context) error {
+		return c.Bind(&testUser{})
+	})
+	e.POST("/validate", func(c echo.Context) error {
+		return c.Validate(testUser{Name: "John", Age: 20, Address: testAddress{Zip: "12345"}})
+	})
+	e.POST("/validate-invalid", func(c echo.Context) error {
+		return c.Validate(testUser{Age: 20, Address: testAddress{Zip: "12345"}})
+	})
+
+	cases := []struct {
+		name         string
+		route        string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			"validation failed",
+			"/bind",
+			`{"name":"","age":18,"address":{"zip":"12345"}}`,
+			http.StatusBadRequest,
+			`{"message":{"message":"request validation failed","details":[{"field":"name","rule":"required","message":"name is required"}]}}`,
+		},
+		{
+			"wrong type",
+			"/bind",
+			`{"name":"John","age":"old","address":{"zip":"12345"}}`,
+			http.StatusBadRequest,
+			`{"message":{"message":"request could not be parsed","details":[{"field":"age","rule":"type","param":"int","message":"age must be of type int"}]}}`,
+		},
+		{
+			"invalid JSON",
+			"/bind",
+			`{"name":`,
+			http.StatusBadRequest,
+			`{"message":{"message":"request could not be parsed","details":[]}}`,
+		},
+		{
+			"success",
+			"/validate",
+			`{}`,
+			http.StatusOK,
+			``,
+		},
+		{
+			"validation error returned by handler",
+			"/validate-invalid",
+			`{}`,
+			http.StatusBadRequest,
+			`{"message":{"message":"request validation failed","details":[{"field":"name","rule":"required","message":"name is required"}]}}`,
+		},
+	}
+
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			testLogger := obs.Logger.(observance.TestLogger)
+			testLogger.Reset()
+
+			req := httptest.NewRequest(http.MethodPost, test.route, strings.NewReader(test.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.expectedCode, rec.Code)
+			assert.Equal(t, test.expectedBody, strings.TrimSpace(rec.Body.String()))
+			assert.Empty(t, testLogger.Entries(), "client errors should not be logged")
+		})
+	}
+
+	t.Run("translated messages", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/validate-invalid", strings.NewReader(`{}`))
+		req.Header.Set("Accept-Language", "fr-CH, de-AT;q=0.9, en;q=0.8")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, "de", rec.Header().Get("Content-Language"))
+		assert.Contains(t, rec.Body.String(), `"message":"name ist ein Pflichtfeld"`)
+	})
+}
@@ -1,18 +1,37 @@
 package server
 
 import (
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+
 	goValidator "github.com/go-playground/validator/v10"
 )
 
 // Validator points to 3rd party validator package (library) which actually does the real validation
 type Validator struct {
 	validator *goValidator.Validate
+	// mu guards the translations, they can be added while the error handler translates messages.
+	mu           sync.RWMutex
+	translations translations
 }
 
-// NewValidator creates new instance of the go-playground/validator
+// NewValidator creates new instance of the go-playground/validator.
+// The validation errors contain the JSON names of the fields (if present) so they match the names used in the request.
+// Additionally to the rules of the validator package the rules iban, vat_id, currency and country are available.
+// Messages for the validation errors are available in English and German.
+// Amounts are validated as float64, so numeric rules like gt=0 can be used for them.
 func NewValidator() *Validator {
+	validator := goValidator.New()
+	validator.RegisterTagNameFunc(fieldName)
+	validator.RegisterCustomTypeFunc(func(v reflect.Value) interface{} {
+		return v.Interface().(Amount).Float64()
+	}, Amount{})
+	registerDefaultRules(validator)
 	return &Validator{
-		validator: goValidator.New(),
+		validator:    validator,
+		translations: loadDefaultTranslations(),
 	}
 }
 
@@ -20,3 +39,94 @@ func NewValidator() *Validator {
 func (v *Validator) Validate(i interface{}) error {
 	return v.validator.Struct(i)
 }
+
+// RegisterRule adds a custom rule that can be used in the validate tag. Existing rules with the same tag are replaced.
+// Like all Register methods it is not safe for concurrent use and needs to be called before the server is started.
+func (v *Validator) RegisterRule(tag string, fn goValidator.Func) error {
+	return v.validator.RegisterValidation(tag, fn)
+}
+
+// RegisterStructRule adds a validation for the given types that has access to all fields of the struct,
+// e.g. to compare two fields. Errors are reported via goValidator.StructLevel.ReportError.
+func (v *Validator) RegisterStructRule(fn goValidator.StructLevelFunc, types ...interface{}) {
+	v.validator.RegisterStructValidation(fn, types...)
+}
+
+// RegisterFieldNameFunc replaces the function that determines the field names used in the validation errors.
+// By default the name from the JSON tag or the name of the path, query or header parameter is used. The names are cached, so it has no effect for structs
+// that were already validated.
+func (v *Validator) RegisterFieldNameFunc(fn goValidator.TagNameFunc) {
+	v.validator.RegisterTagNameFunc(fn)
+}
+
+// AddTranslations adds messages for the given locale (e.g. "de" or "de-AT"), existing messages are replaced.
+// The keys are the rules, the messages can contain the placeholders {field} and {param}.
+// The message with the key "default" is used for rules without an own message.
+// It is safe for concurrent use.
+func (v *Validator) AddTranslations(locale string, messages map[string]string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.translations.add(locale, messages)
+}
+
+// LoadTranslations adds the messages of all JSON files in the root of fsys, e.g. an embed.FS or os.DirFS.
+// The file name without extension is used as locale (e.g. "fr.json"), the content has the format described
+// in AddTranslations. If a file cannot be read or parsed, none of the messages are added.
+// It is safe for concurrent use.
+func (v *Validator) LoadTranslations(fsys fs.FS) error {
+	loaded := translations{}
+	if err := loaded.load(fsys); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for locale, messages := range loaded {
+		v.translations.add(locale, messages)
+	}
+	return nil
+}
+
+// Translate sets the messages of the field errors in the best matching language of the Accept-Language header
+// and returns the locale that was used. If no language matches, DefaultLocale is used.
+func (v *Validator) Translate(details []FieldError, acceptLanguage string) string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.translations.translate(details, acceptLanguage)
+}
+
+// embeddedFieldName is used as name of embedded structs without JSON name, it is removed from the paths of the
+// field errors (see fieldPath). Like in JSON, their fields are reported as fields of the embedding struct.
+const embeddedFieldName = "<embedded>"
+
+// fieldName returns the name of the field from the JSON tag. Fields that are not part of the body use the name
+// of the path, query or header parameter. If there is none, the validator falls back to the name of the struct field.
+func fieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name != "" && name != "-" {
+		return name
+	}
+	if isEmbeddedStruct(field) {
+		return embeddedFieldName
+	}
+	for _, tag := range []string{tagParam, tagQuery, tagHeader} {
+		if name := field.Tag.Get(tag); name != "" {
+			return name
+		}
+	}
+	// Returning "-" would make the validator skip the field.
+	return ""
+}
+
+// isEmbeddedStruct reports whether the field is an embedded struct without JSON name,
+// the JSON decoder treats its fields as fields of the embedding struct.
+func isEmbeddedStruct(field reflect.StructField) bool {
+	if !field.Anonymous || strings.SplitN(field.Tag.Get("json"), ",", 2)[0] != "" {
+		return false
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
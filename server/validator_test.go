@@ -1,9 +1,16 @@
 package server
 
 import (
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
+	"github.com/fastbill/go-httperrors/v2"
+	goValidator "github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type testStruct struct {
@@ -33,3 +40,159 @@ func TestValidate(t *testing.T) {
 		}
 	})
 }
+
+type testPayment struct {
+	IBAN     string `json:"iban" validate:"omitempty,iban"`
+	BIC      string `json:"bic" validate:"omitempty,bic"`
+	VATID    string `json:"vatId" validate:"omitempty,vat_id"`
+	Currency string `json:"currency" validate:"omitempty,currency"`
+	Country  string `json:"country" validate:"omitempty,country"`
+}
+
+func TestDefaultRules(t *testing.T) {
+	v := NewValidator()
+
+	valid := []testPayment{
+		{IBAN: "DE89370400440532013000"},
+		{IBAN: "DE89 3704 0044 0532 0130 00"},
+		{IBAN: "de89 3704 0044 0532 0130 00"},
+		{IBAN: "GB29NWBK60161331926819"},
+		{IBAN: "NO9386011117947"},
+		{BIC: "COBADEFFXXX"},
+		{VATID: "DE123456789"},
+		{VATID: "NL123456789B01"},
+		{VATID: "ATU12345678"},
+		{Currency: "EUR"},
+		{Country: "DE"},
+	}
+	for _, input := range valid {
+		assert.NoError(t, v.Validate(input), "%+v", input)
+	}
+
+	invalid := []testPayment{
+		{IBAN: "DE89370400440532013001"},
+		{IBAN: "DE8937040044"},
+		{IBAN: "DE863704004405320130"}, // valid check digits but too short for DE
+		{IBAN: "XX89370400440532013000"},
+		{BIC: "COBA"},
+		{VATID: "DE12345678"},
+		{VATID: "XX123456789"},
+		{VATID: "DE"},
+		{Currency: "EURO"},
+		{Country: "DEU"},
+	}
+	for _, input := range invalid {
+		assert.Error(t, v.Validate(input), "%+v", input)
+	}
+}
+
+type testRange struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Color string `json:"color" validate:"omitempty,color"`
+}
+
+func TestCustomRules(t *testing.T) {
+	v := NewValidator()
+	require.NoError(t, v.RegisterRule("color", func(fl goValidator.FieldLevel) bool {
+		return fl.Field().String() == "red" || fl.Field().String() == "blue"
+	}))
+	v.RegisterStructRule(func(sl goValidator.StructLevel) {
+		r := sl.Current().Interface().(testRange)
+		if r.From > r.To {
+			sl.ReportError(r.From, "from", "From", "ltefield", "to")
+		}
+	}, testRange{})
+
+	assert.NoError(t, v.Validate(testRange{From: 1, To: 2, Color: "red"}))
+
+	err := NewValidationHTTPError(v.Validate(testRange{From: 3, To: 2, Color: "green"}))
+	httpErr := &httperrors.HTTPError{}
+	require.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, []FieldError{
+		{Field: "color", Rule: "color"},
+		{Field: "from", Rule: "ltefield", Param: "to"},
+	}, httpErr.Message.(*ValidationError).Details)
+
+	t.Run("field names", func(t *testing.T) {
+		v := NewValidator()
+		v.RegisterFieldNameFunc(func(field reflect.StructField) string {
+			return strings.ToUpper(field.Name)
+		})
+		err := v.Validate(testStruct{})
+		assert.Contains(t, err.Error(), "testStruct.FOO")
+	})
+}
+
+func TestTranslate(t *testing.T) {
+	v := NewValidator()
+	details := func() []FieldError {
+		return []FieldError{
+			{Field: "name", Rule: "required"},
+			{Field: "zip", Rule: "len", Param: "5"},
+			{Field: "color", Rule: "color"},
+		}
+	}
+
+	t.Run("default locale", func(t *testing.T) {
+		d := details()
+		assert.Equal(t, "en", v.Translate(d, ""))
+		assert.Equal(t, "name is required", d[0].Message)
+		assert.Equal(t, "zip must have a length of 5", d[1].Message)
+		assert.Equal(t, "color is invalid", d[2].Message)
+	})
+
+	t.Run("accept language", func(t *testing.T) {
+		cases := map[string]string{
+			"de":                         "de",
+			"de-DE":                      "de",
+			"fr, de;q=0.5":               "de",
+			"en;q=0.5, de;q=0.8":         "de",
+			"de;q=0, en":                 "en",
+			"*":                          "en",
+			"invalid;;q=abc, de_AT;q=.3": "de",
+		}
+		for header, expected := range cases {
+			assert.Equal(t, expected, v.Translate(details(), header), header)
+		}
+	})
+
+	t.Run("custom translations", func(t *testing.T) {
+		v.AddTranslations("de", map[string]string{"color": "{field} muss rot oder blau sein"})
+		require.NoError(t, v.LoadTranslations(fstest.MapFS{
+			"fr.json":   {Data: []byte(`{"required": "{field} est obligatoire", "default": "{field} n'est pas valide"}`)},
+			"README.md": {Data: []byte("ignored")},
+		}))
+
+		d := details()
+		v.Translate(d, "de")
+		assert.Equal(t, "color muss rot oder blau sein", d[2].Message)
+
+		d = details()
+		assert.Equal(t, "fr", v.Translate(d, "fr-FR"))
+		assert.Equal(t, "name est obligatoire", d[0].Message)
+		assert.Equal(t, "zip n'est pas valide", d[1].Message)
+
+		assert.Error(t, v.LoadTranslations(fstest.MapFS{
+			"es.json": {Data: []byte(`{"required": "{field} es obligatorio"}`)},
+			"it.json": {Data: []byte(`{`)},
+		}))
+		assert.Equal(t, "en", v.Translate(details(), "es"), "no messages are added if a file is invalid")
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				v.AddTranslations("nl", map[string]string{"required": "{field} is verplicht"})
+			}()
+			go func() {
+				defer wg.Done()
+				v.Translate(details(), "nl")
+			}()
+		}
+		wg.Wait()
+	})
+}
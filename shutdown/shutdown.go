@@ -0,0 +1,238 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+// The order defines in which sequence the hooks are executed, lower values first.
+// Hooks with the same order are executed in the order they were registered.
+// The predefined values can be used to sort the typical components of a service.
+const (
+	// OrderServer is used for HTTP servers so no new requests are accepted and ongoing requests can finish.
+	OrderServer = 100
+	// OrderWorkers is used for background workers that might still need the connections.
+	OrderWorkers = 200
+	// OrderConnections is used for closing connections e.g. to the database or the cache.
+	OrderConnections = 300
+	// OrderMetrics is used for pushing the metrics a last time.
+	OrderMetrics = 400
+)
+
+const defaultTimeout = 15 * time.Second
+
+// Hook is called during the shutdown. The context expires when the total shutdown deadline is reached.
+type Hook func(ctx context.Context) error
+
+// Closer creates a hook from anything with a Close method, e.g. cache.RedisClient or observance.PrometheusMetrics.
+func Closer(closer io.Closer) Hook {
+	return func(ctx context.Context) error {
+		return closer.Close()
+	}
+}
+
+// Func creates a hook from a function that does not need the context.
+func Func(fn func() error) Hook {
+	return func(ctx context.Context) error {
+		return fn()
+	}
+}
+
+// Config contains the settings for the shutdown manager.
+type Config struct {
+	// Timeout is the total deadline for the shutdown including the drain delay. The default is 15 seconds.
+	// Hooks that did not start before the deadline are skipped.
+	Timeout time.Duration
+	// DrainDelay is the time to wait after the shutdown was triggered before the hooks are executed (optional).
+	// During that time the service keeps serving requests while the load balancer notices it is not ready anymore.
+	DrainDelay time.Duration
+	// Signals that trigger the shutdown. The default is SIGINT and SIGTERM.
+	Signals []os.Signal
+}
+
+// HookError describes a hook that failed during the shutdown.
+type HookError struct {
+	Name string
+	Err  error
+}
+
+// Error summarizes all hooks that failed during the shutdown.
+type Error struct {
+	HookErrors []HookError
+}
+
+// Error lists the names and errors of all failed hooks.
+func (e *Error) Error() string {
+	messages := make([]string, 0, len(e.HookErrors))
+	for _, hookErr := range e.HookErrors {
+		messages = append(messages, fmt.Sprintf("%s: %s", hookErr.Name, hookErr.Err))
+	}
+	return fmt.Sprintf("%d shutdown hook(s) failed: %s", len(e.HookErrors), strings.Join(messages, "; "))
+}
+
+type registeredHook struct {
+	name  string
+	order int
+	hook  Hook
+}
+
+// Manager coordinates the graceful shutdown of all components of the service.
+// Components register hooks that are executed in order when a signal is received or Shutdown is called.
+type Manager struct {
+	config  Config
+	logger  observance.Logger
+	signals chan os.Signal
+	trigger chan struct{}
+	done    chan struct{}
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu            sync.Mutex
+	hooks         []registeredHook
+	onStart       []func()
+	triggerCalled bool
+	err           error
+}
+
+// New creates a new shutdown manager and starts listening for the configured signals.
+func New(config Config, logger observance.Logger) *Manager {
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	if len(config.Signals) == 0 {
+		config.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		config:  config,
+		logger:  logger,
+		signals: make(chan os.Signal, 1),
+		trigger: make(chan struct{}),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	signal.Notify(m.signals, config.Signals...)
+	go m.run()
+
+	return m
+}
+
+// Register adds a hook that is executed during the shutdown. Hooks with a lower order are executed first.
+// The name is used for logging and in the returned error.
+func (m *Manager) Register(name string, order int, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, registeredHook{name: name, order: order, hook: hook})
+}
+
+// OnShutdownStart adds a function that is called as soon as the shutdown is triggered, before the drain delay.
+// It can be used e.g. to let the readiness check fail.
+func (m *Manager) OnShutdownStart(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onStart = append(m.onStart, fn)
+}
+
+// Context returns a context that is cancelled as soon as the shutdown is triggered.
+// Background workers can use it to stop their work.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Shutdown triggers the shutdown manually, e.g. if the server could not be started.
+// Calling it multiple times has no additional effect.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.triggerCalled {
+		m.triggerCalled = true
+		close(m.trigger)
+	}
+}
+
+// Wait blocks until the shutdown is completed. It returns an *Error if any hook failed.
+func (m *Manager) Wait() error {
+	<-m.done
+	return m.err
+}
+
+func (m *Manager) run() {
+	select {
+	case s := <-m.signals:
+		m.logger.WithField("signal", s).Warn("shutting down gracefully")
+	case <-m.trigger:
+		m.logger.Warn("shutting down gracefully")
+	}
+	signal.Stop(m.signals)
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.config.Timeout)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := make([]registeredHook, len(m.hooks))
+	copy(hooks, m.hooks)
+	onStart := m.onStart
+	m.mu.Unlock()
+
+	for _, fn := range onStart {
+		fn()
+	}
+	m.cancel()
+
+	if m.config.DrainDelay > 0 {
+		select {
+		case <-time.After(m.config.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].order < hooks[j].order
+	})
+
+	hookErrors := []HookError{}
+	for _, h := range hooks {
+		if err := runHook(ctx, h.hook); err != nil {
+			m.logger.WithField("hook", h.name).WithError(err).Error("shutdown hook failed")
+			hookErrors = append(hookErrors, HookError{Name: h.name, Err: err})
+		}
+	}
+
+	if len(hookErrors) > 0 {
+		m.err = &Error{HookErrors: hookErrors}
+	}
+	close(m.done)
+}
+
+// runHook returns as soon as the deadline is reached, even if the hook itself does not respect the context.
+// If the deadline was already reached before, the hook is skipped.
+func runHook(ctx context.Context, hook Hook) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("hook skipped, the shutdown deadline was reached: %w", ctx.Err())
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- hook(ctx)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("hook did not finish before the shutdown deadline: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,124 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/fastbill/go-service-toolkit/v4/observance"
+)
+
+type testCloser struct {
+	closed bool
+}
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	t.Run("hooks are executed in order", func(t *testing.T) {
+		m := New(Config{}, observance.NewTestLogger())
+		calls := []string{}
+		mu := sync.Mutex{}
+		record := func(name string) Hook {
+			return func(ctx context.Context) error {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, name)
+				return nil
+			}
+		}
+
+		m.Register("metrics", OrderMetrics, record("metrics"))
+		m.Register("db", OrderConnections, record("db"))
+		m.Register("cache", OrderConnections, record("cache"))
+		m.Register("server", OrderServer, record("server"))
+		closer := &testCloser{}
+		m.Register("closer", OrderConnections, Closer(closer))
+
+		m.Shutdown()
+		m.Shutdown()
+		assert.NoError(t, m.Wait())
+		assert.Equal(t, []string{"server", "db", "cache", "metrics"}, calls)
+		assert.True(t, closer.closed)
+	})
+
+	t.Run("shutdown start and context", func(t *testing.T) {
+		m := New(Config{DrainDelay: 50 * time.Millisecond}, observance.NewTestLogger())
+		started := make(chan time.Time, 1)
+		m.OnShutdownStart(func() {
+			started <- time.Now()
+		})
+		var hookCalled time.Time
+		m.Register("worker", OrderWorkers, func(ctx context.Context) error {
+			hookCalled = time.Now()
+			return nil
+		})
+
+		assert.NoError(t, m.Context().Err())
+		m.Shutdown()
+		require.NoError(t, m.Wait())
+
+		assert.Error(t, m.Context().Err())
+		assert.GreaterOrEqual(t, int64(hookCalled.Sub(<-started)), int64(50*time.Millisecond), "drain delay")
+	})
+
+	t.Run("failing hooks", func(t *testing.T) {
+		m := New(Config{Timeout: 50 * time.Millisecond}, observance.NewTestLogger())
+		m.Register("db", OrderConnections, Func(func() error { return errors.New("testError") }))
+		m.Register("worker", OrderWorkers, func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+		m.Register("metrics", OrderMetrics, Func(func() error { return nil }))
+
+		m.Shutdown()
+		err := m.Wait()
+
+		shutdownErr := &Error{}
+		require.ErrorAs(t, err, &shutdownErr)
+		require.Len(t, shutdownErr.HookErrors, 3)
+		assert.Equal(t, "worker", shutdownErr.HookErrors[0].Name)
+		assert.ErrorIs(t, shutdownErr.HookErrors[0].Err, context.DeadlineExceeded)
+		assert.Equal(t, "db", shutdownErr.HookErrors[1].Name)
+		assert.Contains(t, shutdownErr.HookErrors[1].Err.Error(), "skipped")
+		assert.Equal(t, "metrics", shutdownErr.HookErrors[2].Name)
+		assert.Contains(t, err.Error(), "3 shutdown hook(s) failed")
+	})
+
+	t.Run("error summary", func(t *testing.T) {
+		m := New(Config{}, observance.NewTestLogger())
+		m.Register("db", OrderConnections, Func(func() error { return errors.New("testError") }))
+		m.Register("cache", OrderConnections, Func(func() error { return nil }))
+
+		m.Shutdown()
+		err := m.Wait()
+		assert.EqualError(t, err, "1 shutdown hook(s) failed: db: testError")
+	})
+
+	t.Run("signal", func(t *testing.T) {
+		m := New(Config{}, observance.NewTestLogger())
+		called := false
+		m.Register("server", OrderServer, Func(func() error {
+			called = true
+			return nil
+		}))
+
+		process, err := os.FindProcess(os.Getpid())
+		require.NoError(t, err)
+		if err := process.Signal(os.Interrupt); err != nil {
+			t.Skip("sending signals is not supported on this platform")
+		}
+
+		assert.NoError(t, m.Wait())
+		assert.True(t, called)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/fastbill/go-service-toolkit/v4/envloader"
 	"github.com/fastbill/go-service-toolkit/v4/observance"
 	"github.com/fastbill/go-service-toolkit/v4/server"
+	"github.com/fastbill/go-service-toolkit/v4/shutdown"
 )
 
 // MustLoadEnvs checks and loads environment variables from the given folder.
@@ -25,6 +26,9 @@ type ObsConfig = observance.Config
 // DBConfig aliases database.Config so it will not be necessary to import the database package for the setup process.
 type DBConfig = database.Config
 
+// ShutdownConfig aliases shutdown.Config so it will not be necessary to import the shutdown package for the setup process.
+type ShutdownConfig = shutdown.Config
+
 // MustNewObs creates a new observability instance.
 // It includes the properties "Logger", a Logrus logger that fulfils the Logger interface
 // and "Metrics", a Prometheus Client that fulfils the Measurer interface.
@@ -71,6 +75,31 @@ func MustNewServer(obs *observance.Obs, CORSOrigins string, timeout ...string) (
 	return echoServer, connectionsClosed
 }
 
+// MustNewServerWithOptions sets up a new Echo server with the given options, see server.NewWithOptions.
+func MustNewServerWithOptions(obs *observance.Obs, opts ...server.Option) (*echo.Echo, chan struct{}) {
+	echoServer, connectionsClosed, err := server.NewWithOptions(obs, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return echoServer, connectionsClosed
+}
+
+// MustNewAdminServer sets up the admin server for the operational endpoints, see server.NewAdminServer.
+// Pass it to MustNewServerWithOptions via server.WithAdminServer.
+func MustNewAdminServer(obs *observance.Obs, config server.AdminConfig) *server.AdminServer {
+	admin, err := server.NewAdminServer(obs, config)
+	if err != nil {
+		panic(err)
+	}
+	return admin
+}
+
+// NewShutdownManager creates a manager that coordinates the graceful shutdown of all registered components.
+// It starts listening for the configured signals immediately.
+func NewShutdownManager(config ShutdownConfig, logger observance.Logger) *shutdown.Manager {
+	return shutdown.New(config, logger)
+}
+
 // CloseDatabase closes the database instance used by GORM.
 // db.Close() was removed in GORM v2.
 func CloseDatabase(db *gorm.DB) error {